@@ -0,0 +1,184 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/internal/extract"
+	"github.com/gandrille/go-commons/result"
+	"github.com/gandrille/go-commons/zipfile"
+)
+
+// Format of an archive file.
+type Format int
+
+const (
+	Unknown Format = iota
+	Zip
+	Tar
+	TarGzip
+	TarBzip2
+	TarXz
+	TarZstd
+)
+
+func (f Format) String() string {
+	return formatToString[f]
+}
+
+var formatToString = map[Format]string{
+	Unknown:  "Unknown",
+	Zip:      "zip",
+	Tar:      "tar",
+	TarGzip:  "tar.gz",
+	TarBzip2: "tar.bz2",
+	TarXz:    "tar.xz",
+	TarZstd:  "tar.zst",
+}
+
+// ExtractOptions configures the extraction of an archive.
+type ExtractOptions = extract.Options
+
+// Entry is a file (or a folder, or a symlink) inside an archive.
+// zipfile.ZipEntry implements it.
+type Entry interface {
+	Name() string // archive relative path, ending with "/" for folders
+	Size() uint64
+	Mode() os.FileMode
+	ModTime() time.Time
+	IsDir() bool
+	Open() (io.ReadCloser, error) // content of the entry, target of the link for symlinks
+}
+
+// Archive is an open archive file, whatever its format.
+// It MUST be closed after usage.
+// As an fs.FS, folders which are NOT stored in the archive are synthesized from the names of the files.
+type Archive interface {
+	fs.ReadDirFS
+	fs.GlobFS
+	fs.StatFS
+	Path() string
+	Format() Format
+	Entries() []Entry
+	HasFile(name string) bool
+	GetFile(name string) Entry // nil if NOT found
+	FilesStartingWith(prefix string) []Entry
+	Load() zipfile.ZipFile // reads the content of all the entries into memory
+	ExtractAll(dest string, options ExtractOptions) result.Set
+	ExtractPrefix(prefix, dest string, options ExtractOptions) result.Set
+	Close() error
+}
+
+// Open opens an archive file, the format being detected from its content (see DetectFormat).
+// For tar files, the full archive is read once to list the entries.
+func Open(filePath string) (Archive, error) {
+	filePath = strings.Replace(filePath, "~", filesystem.HomeDir(), 1)
+
+	format, err := DetectFormat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case Zip:
+		return openZip(filePath)
+	case Unknown:
+		return nil, errors.New(filePath + " is not a supported archive")
+	default:
+		return openTar(filePath, format)
+	}
+}
+
+// Load loads an archive file into memory, like zipfile.Open does for zip files.
+func Load(filePath string) zipfile.ZipFile {
+	archive, err := Open(filePath)
+	if err != nil {
+		return zipfile.NewZipFile(nil, err)
+	}
+	defer archive.Close()
+
+	return archive.Load()
+}
+
+// ExtractAll extracts all the entries of an archive file into a destination folder.
+// See zipfile.ZipArchive.ExtractAll for the safety checks.
+func ExtractAll(filePath, dest string, options ExtractOptions) result.Set {
+	return ExtractPrefix(filePath, "", dest, options)
+}
+
+// ExtractPrefix extracts the entries of an archive file starting with a prefix into a destination folder.
+// See zipfile.ZipArchive.ExtractPrefix.
+func ExtractPrefix(filePath, prefix, dest string, options ExtractOptions) result.Set {
+	archive, err := Open(filePath)
+	if err != nil {
+		return result.NewSet([]result.Result{result.FromError(err, "")}, "Extraction of "+filePath+" into "+dest)
+	}
+	defer archive.Close()
+
+	return archive.ExtractPrefix(prefix, dest, options)
+}
+
+// DetectFormat detects the format of an archive file from its first bytes.
+// Compressed files are expected to be compressed tar files.
+func DetectFormat(filePath string) (Format, error) {
+	filePath = strings.Replace(filePath, "~", filesystem.HomeDir(), 1)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Unknown, errors.New(filePath + " can't open file: " + err.Error())
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Unknown, errors.New(filePath + " can't read file: " + err.Error())
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return Zip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return TarGzip, nil
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return TarBzip2, nil
+	case bytes.HasPrefix(header, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return TarXz, nil
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return TarZstd, nil
+	case isTarHeader(header):
+		return Tar, nil
+	}
+	return Unknown, nil
+}
+
+// isTarHeader checks the magic ("ustar"), or the checksum for old tar files without magic.
+func isTarHeader(header []byte) bool {
+	if len(header) < 512 {
+		return false
+	}
+	if bytes.Equal(header[257:262], []byte("ustar")) {
+		return true
+	}
+
+	expected, err := strconv.ParseInt(strings.Trim(string(header[148:156]), " \x00"), 8, 64)
+	if err != nil {
+		return false
+	}
+	var sum int64
+	for i, b := range header {
+		if i >= 148 && i < 156 {
+			b = ' '
+		}
+		sum += int64(b)
+	}
+	return sum == expected
+}
@@ -0,0 +1,450 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/internal/archivefs"
+	"github.com/gandrille/go-commons/internal/extract"
+	"github.com/gandrille/go-commons/result"
+	"github.com/gandrille/go-commons/zipfile"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// tarArchive is an open tar file, possibly compressed.
+// Since compressed streams can't be seeked, the file is read again from its beginning
+// each time the content of an entry is opened, up to this entry.
+type tarArchive struct {
+	path    string
+	format  Format
+	entries []*tarEntry
+	index   map[string]int
+	fsys    *archivefs.FS
+}
+
+// tarEntry is a file inside a tar file.
+type tarEntry struct {
+	archive  *tarArchive
+	header   tar.Header
+	name     string
+	position int       // number of headers before this one in the tar stream
+	link     *tarEntry // for hard links, the regular file they point to, nil if it is NOT found
+}
+
+func openTar(filePath string, format Format) (Archive, error) {
+	archive := &tarArchive{filePath, format, nil, map[string]int{}, nil}
+	previous := map[string]*tarEntry{} // last entry with each name, to resolve hard links
+
+	err := archive.scan(func(tr *tar.Reader, header *tar.Header, position int) (bool, error) {
+		name := entryName(header)
+		if name == "" {
+			return true, nil
+		}
+		if _, found := archive.index[name]; !found {
+			archive.index[name] = len(archive.entries)
+		}
+		entry := &tarEntry{archive, *header, name, position, nil}
+		if header.Typeflag == tar.TypeLink {
+			entry.link = resolveLink(previous[strings.TrimPrefix(path.Clean(header.Linkname), "./")])
+		}
+		previous[name] = entry
+		archive.entries = append(archive.entries, entry)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var fsEntries []archivefs.Entry
+	for _, entry := range archive.entries {
+		fsEntries = append(fsEntries, entry)
+	}
+	archive.fsys = archivefs.New(fsEntries)
+	return archive, nil
+}
+
+func (archive *tarArchive) Path() string {
+	return archive.path
+}
+
+func (archive *tarArchive) Format() Format {
+	return archive.format
+}
+
+// Close does nothing, since the file is only opened while reading it.
+func (archive *tarArchive) Close() error {
+	return nil
+}
+
+func (archive *tarArchive) Entries() []Entry {
+	var list []Entry
+	for _, entry := range archive.entries {
+		list = append(list, entry)
+	}
+	return list
+}
+
+func (archive *tarArchive) HasFile(name string) bool {
+	_, found := archive.index[name]
+	return found
+}
+
+func (archive *tarArchive) GetFile(name string) Entry {
+	if idx, found := archive.index[name]; found {
+		return archive.entries[idx]
+	}
+	return nil
+}
+
+func (archive *tarArchive) FilesStartingWith(prefix string) []Entry {
+	var list []Entry
+	for _, entry := range archive.entries {
+		if strings.HasPrefix(entry.name, prefix) {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// Open opens a file or a folder, for fs.FS.
+// Reading a file reads the tar file from its beginning (see tarEntry.Open).
+func (archive *tarArchive) Open(name string) (fs.File, error) {
+	return archive.fsys.Open(name)
+}
+
+func (archive *tarArchive) ReadDir(name string) ([]fs.DirEntry, error) {
+	return archive.fsys.ReadDir(name)
+}
+
+func (archive *tarArchive) Glob(pattern string) ([]string, error) {
+	return archive.fsys.Glob(pattern)
+}
+
+func (archive *tarArchive) Stat(name string) (fs.FileInfo, error) {
+	return archive.fsys.Stat(name)
+}
+
+func (archive *tarArchive) Load() zipfile.ZipFile {
+	var files []zipfile.ZipElement
+	var invalid []string
+
+	err := archive.scan(func(tr *tar.Reader, header *tar.Header, position int) (bool, error) {
+		name := entryName(header)
+		if name == "" {
+			return true, nil
+		}
+
+		var content []byte
+		var err error
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			content = []byte(header.Linkname)
+		case tar.TypeLink:
+			content, err = archive.readLink(header.Linkname, files)
+		default:
+			content, err = ioutil.ReadAll(tr)
+		}
+		if err != nil {
+			invalid = append(invalid, name)
+		}
+		files = append(files, zipfile.NewZipElement(name, content, err))
+		return true, nil
+	})
+
+	if err != nil {
+		return zipfile.NewZipFile(files, err)
+	}
+	if len(invalid) != 0 {
+		return zipfile.NewZipFile(files, errors.New(archive.path+" can't read embedded files: "+strings.Join(invalid, ",")))
+	}
+	return zipfile.NewZipFile(files, nil)
+}
+
+func (archive *tarArchive) ExtractAll(dest string, options ExtractOptions) result.Set {
+	return archive.ExtractPrefix("", dest, options)
+}
+
+// ExtractPrefix extracts the entries starting with a prefix, reading the tar file only once.
+// Hard links are extracted as copies of the file they point to, which is copied into a temporary file
+// when the tar file is read (and never read from the destination folder, which could contain symlinks).
+func (archive *tarArchive) ExtractPrefix(prefix, dest string, options ExtractOptions) result.Set {
+	results := result.NewSet(nil, "Extraction of "+archive.path+" into "+dest)
+
+	links := map[int]*tarEntry{}
+	targets := map[int]bool{} // positions of the files pointed by the extracted hard links
+	for _, entry := range archive.entries {
+		if entry.header.Typeflag == tar.TypeLink {
+			links[entry.position] = entry
+			if entry.link != nil && strings.HasPrefix(entry.name, prefix) && entry.name != prefix {
+				targets[entry.link.position] = true
+			}
+		}
+	}
+
+	spooled := map[int]string{} // position -> temporary file holding the content
+	defer func() {
+		for _, filePath := range spooled {
+			os.Remove(filePath)
+		}
+	}()
+
+	x, err := extract.New(dest, options)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't extract "+archive.path))
+		return results
+	}
+
+	err = archive.scan(func(tr *tar.Reader, header *tar.Header, position int) (bool, error) {
+		var content io.Reader = tr
+		if targets[position] {
+			filePath, err := spool(tr)
+			if err != nil {
+				return false, errors.New(archive.path + " can't read " + header.Name + ": " + err.Error())
+			}
+			spooled[position] = filePath
+			content = nil
+		}
+
+		name := entryName(header)
+		if name == "" || !strings.HasPrefix(name, prefix) || name == prefix {
+			return true, nil
+		}
+		name = strings.TrimPrefix(name, prefix)
+
+		mode := header.FileInfo().Mode()
+		open := func() (io.ReadCloser, error) { return ioutil.NopCloser(content), nil }
+		if content == nil {
+			open = func() (io.ReadCloser, error) { return os.Open(spooled[position]) }
+		}
+
+		switch header.Typeflag {
+		case tar.TypeSymlink:
+			open = func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader(header.Linkname)), nil }
+		case tar.TypeLink:
+			entry := links[position]
+			if entry == nil || entry.link == nil {
+				results.Add(result.NewError(name + " is rejected: it is a hard link to a file which is NOT in the archive"))
+				return true, nil
+			}
+			filePath := spooled[entry.link.position]
+			open = func() (io.ReadCloser, error) { return os.Open(filePath) }
+		}
+
+		if res, extracted := x.Extract(name, mode, header.ModTime, open); extracted {
+			results.Add(res)
+		}
+		return true, nil
+	})
+	if err != nil {
+		results.Add(result.FromError(err, "Can't extract "+archive.path))
+	}
+
+	for _, res := range x.Finish() {
+		results.Add(res)
+	}
+	return results
+}
+
+// spool copies the content of the current entry into a temporary file.
+func spool(r io.Reader) (string, error) {
+	tmp, err := ioutil.TempFile("", "tar-link-")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// =============================================
+
+// tarStream is a tar file being read from its beginning.
+// Read gives the content of the current entry.
+type tarStream struct {
+	*tar.Reader
+	file   *os.File
+	stream io.ReadCloser
+}
+
+func (archive *tarArchive) openStream() (*tarStream, error) {
+	file, err := os.Open(archive.path)
+	if err != nil {
+		return nil, errors.New(archive.path + " can't open file: " + err.Error())
+	}
+
+	stream, err := decompress(file, archive.format)
+	if err != nil {
+		file.Close()
+		return nil, errors.New(archive.path + " can't decompress file: " + err.Error())
+	}
+
+	return &tarStream{tar.NewReader(stream), file, stream}, nil
+}
+
+// Close closes the decompressor and the file.
+func (s *tarStream) Close() error {
+	s.stream.Close()
+	return s.file.Close()
+}
+
+// scan reads the tar file from its beginning, calling fn for each header until it returns false.
+func (archive *tarArchive) scan(fn func(tr *tar.Reader, header *tar.Header, position int) (bool, error)) error {
+	s, err := archive.openStream()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	tr := s.Reader
+	for position := 0; ; position++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New(archive.path + " can't read tar file: " + err.Error())
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		next, err := fn(tr, header, position)
+		if err != nil || !next {
+			return err
+		}
+	}
+}
+
+// resolveLink gets the regular file a hard link points to.
+// Since only the entries located before the link in the stream are known, loops are NOT possible.
+func resolveLink(target *tarEntry) *tarEntry {
+	if target != nil && target.header.Typeflag == tar.TypeLink {
+		target = target.link
+	}
+	if target == nil || !target.Mode().IsRegular() {
+		return nil
+	}
+	return target
+}
+
+// readLink gets the content of the file a hard link points to, among the already loaded files.
+func (archive *tarArchive) readLink(linkName string, files []zipfile.ZipElement) ([]byte, error) {
+	linkName = strings.TrimPrefix(path.Clean(linkName), "./")
+	for idx := len(files) - 1; idx >= 0; idx-- {
+		if files[idx].Name() == linkName {
+			return files[idx].BytesContent()
+		}
+	}
+	return nil, errors.New(linkName + " not found")
+}
+
+func decompress(r io.Reader, format Format) (io.ReadCloser, error) {
+	switch format {
+	case TarGzip:
+		return gzip.NewReader(r)
+	case TarBzip2:
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	case TarXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	case TarZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return ioutil.NopCloser(r), nil
+	}
+}
+
+// entryName gets the name of an entry without any leading "./", ending with "/" for folders.
+// The root folder has an empty name.
+func entryName(header *tar.Header) string {
+	name := header.Name
+	for strings.HasPrefix(name, "./") {
+		name = name[2:]
+	}
+	if name == "." {
+		name = ""
+	}
+	if header.Typeflag == tar.TypeDir && name != "" && !strings.HasSuffix(name, "/") {
+		name += "/"
+	}
+	return name
+}
+
+// =============================================
+
+func (entry *tarEntry) Name() string {
+	return entry.name
+}
+
+// Size gets the size of the content, the one of the file it points to for hard links.
+func (entry *tarEntry) Size() uint64 {
+	if entry.link != nil {
+		return entry.link.Size()
+	}
+	return uint64(entry.header.Size)
+}
+
+func (entry *tarEntry) Mode() os.FileMode {
+	return entry.header.FileInfo().Mode()
+}
+
+func (entry *tarEntry) ModTime() time.Time {
+	return entry.header.ModTime
+}
+
+func (entry *tarEntry) IsDir() bool {
+	return entry.header.Typeflag == tar.TypeDir
+}
+
+// Open reads the tar file until the entry, then streams its content.
+// The tar file is closed with the returned reader.
+func (entry *tarEntry) Open() (io.ReadCloser, error) {
+	switch entry.header.Typeflag {
+	case tar.TypeSymlink:
+		return ioutil.NopCloser(strings.NewReader(entry.header.Linkname)), nil
+	case tar.TypeLink:
+		if entry.link != nil {
+			return entry.link.Open()
+		}
+		return nil, errors.New(entry.name + " is a hard link to a missing file")
+	}
+
+	s, err := entry.archive.openStream()
+	if err != nil {
+		return nil, errors.New(entry.name + " can't be opened: " + err.Error())
+	}
+	for position := 0; position <= entry.position; position++ {
+		if _, err := s.Next(); err != nil {
+			s.Close()
+			if err == io.EOF {
+				return nil, errors.New(entry.name + " can't be opened: not found")
+			}
+			return nil, errors.New(entry.name + " can't be opened: " + err.Error())
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,46 @@
+package archive
+
+import (
+	"github.com/gandrille/go-commons/zipfile"
+)
+
+// zipArchive adapts zipfile.ZipArchive to the Archive interface.
+// Extraction and loading are done by zipfile.
+type zipArchive struct {
+	*zipfile.ZipArchive
+}
+
+func openZip(filePath string) (Archive, error) {
+	archive, err := zipfile.OpenArchive(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return zipArchive{archive}, nil
+}
+
+func (archive zipArchive) Format() Format {
+	return Zip
+}
+
+func (archive zipArchive) Entries() []Entry {
+	return zipEntries(archive.ZipArchive.Entries())
+}
+
+func (archive zipArchive) GetFile(name string) Entry {
+	if entry := archive.ZipArchive.GetFile(name); entry != nil {
+		return *entry
+	}
+	return nil
+}
+
+func (archive zipArchive) FilesStartingWith(prefix string) []Entry {
+	return zipEntries(archive.ZipArchive.FilesStartingWith(prefix))
+}
+
+func zipEntries(entries []zipfile.ZipEntry) []Entry {
+	var list []Entry
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	return list
+}
@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/env"
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/result"
+)
+
+// Audit log of the runs.
+// Each run is appended as a single JSON line to a local file, which is never rewritten.
+// Nothing is recorded unless Log.Record is called.
+
+// DefaultLogFile is the location of the default audit log, relative to $XDG_STATE_HOME.
+const DefaultLogFile = "go-commons/audit.jsonl"
+
+// Log is an append-only audit log file.
+type Log struct {
+	path string
+}
+
+// Run is a recorded execution.
+type Run struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Host    string    `json:"host"`
+	User    string    `json:"user"`
+	Time    time.Time `json:"time"`
+	Entries []Entry   `json:"entries"`
+}
+
+// Entry is a recorded result.
+type Entry struct {
+	Group    string         `json:"group,omitempty"`
+	Status   result.Status  `json:"status"`
+	Subject  string         `json:"subject,omitempty"` // what the result is about (ie an absolute file path), see result.Result.Subject
+	Message  string         `json:"message"`
+	Diff     string         `json:"diff,omitempty"` // see result.Result.Diff
+	Restart  result.Restart `json:"restart,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Duration time.Duration  `json:"duration,omitempty"`
+}
+
+// Change is an entry which modified the system, with the run it belongs to.
+type Change struct {
+	Run   Run
+	Entry Entry
+}
+
+// NewLog constructs a Log object.
+// ~ is expanded to the home directory.
+func NewLog(path string) Log {
+	return Log{strings.Replace(path, "~", filesystem.HomeDir(), 1)}
+}
+
+// DefaultLog gets the audit log of the current user (see DefaultLogFile).
+func DefaultLog() Log {
+	return Log{env.StateFilePath(DefaultLogFile)}
+}
+
+// Path getter.
+func (log Log) Path() string {
+	return log.path
+}
+
+// Record appends a run to the log.
+// The host, the user and the time of the run are the current ones.
+func (log Log) Record(name string, results result.Set) (Run, error) {
+	now := time.Now()
+	run := Run{
+		ID:   now.UTC().Format("20060102T150405.000000000Z") + "-" + strconv.Itoa(os.Getpid()),
+		Name: name,
+		Host: env.Hostname(),
+		User: env.Username(),
+		Time: now,
+	}
+	results.Walk(func(group string, res result.Result) {
+		run.Entries = append(run.Entries, newEntry(group, res))
+	})
+
+	line, err := json.Marshal(run)
+	if err != nil {
+		return run, errors.New("Can't encode run " + name + ": " + err.Error())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(log.path), 0700); err != nil {
+		return run, errors.New("Can't create audit log folder: " + err.Error())
+	}
+	file, err := os.OpenFile(log.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return run, errors.New("Can't open audit log " + log.path + ": " + err.Error())
+	}
+	defer file.Close()
+
+	// A single write, so that concurrent runs do NOT interleave their lines
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return run, errors.New("Can't write audit log " + log.path + ": " + err.Error())
+	}
+	return run, nil
+}
+
+// Runs gets all the recorded runs, the oldest first.
+// If the log file does NOT exist, no run is returned.
+func (log Log) Runs() ([]Run, error) {
+	file, err := os.Open(log.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("Can't open audit log " + log.path + ": " + err.Error())
+	}
+	defer file.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64*1024*1024)
+	for lineNb := 1; scanner.Scan(); lineNb++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var run Run
+		if err := json.Unmarshal([]byte(line), &run); err != nil {
+			return runs, errors.New("Can't decode line " + strconv.Itoa(lineNb) + " of audit log " + log.path + ": " + err.Error())
+		}
+		runs = append(runs, run)
+	}
+	if err := scanner.Err(); err != nil {
+		return runs, errors.New("Can't read audit log " + log.path + ": " + err.Error())
+	}
+	return runs, nil
+}
+
+// Changes gets the entries which modified the system (see Entry.IsChange), the oldest first.
+// Only the runs executed on host (all hosts if empty) since a given time are considered.
+func (log Log) Changes(host string, since time.Time) ([]Change, error) {
+	runs, err := log.Runs()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, run := range runs {
+		if (host != "" && run.Host != host) || run.Time.Before(since) {
+			continue
+		}
+		for _, entry := range run.Entries {
+			if entry.IsChange() {
+				changes = append(changes, Change{run, entry})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// LastChange gets the most recent change about a subject (ie a file path or a key).
+// File paths are compared once "~" expanded and cleaned.
+// Returns false if no change has been found.
+func (log Log) LastChange(subject string) (Change, bool, error) {
+	changes, err := log.Changes("", time.Time{})
+	if err != nil {
+		return Change{}, false, err
+	}
+
+	subject = normalizeSubject(subject)
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].Entry.Subject != "" && normalizeSubject(changes[i].Entry.Subject) == subject {
+			return changes[i], true, nil
+		}
+	}
+	return Change{}, false, nil
+}
+
+// =============================================
+
+func newEntry(group string, res result.Result) Entry {
+	entry := Entry{
+		Group:    group,
+		Status:   res.Status(),
+		Subject:  normalizeSubject(res.Subject()),
+		Message:  res.Message(),
+		Diff:     res.Diff(),
+		Restart:  res.Restart(),
+		Duration: res.Duration(),
+	}
+	if err := res.Err(); err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// normalizeSubject expands "~" and cleans file paths, so that a file always has the same subject.
+func normalizeSubject(subject string) string {
+	if strings.HasPrefix(subject, "~") {
+		subject = strings.Replace(subject, "~", filesystem.HomeDir(), 1)
+	}
+	if filepath.IsAbs(subject) {
+		return filepath.Clean(subject)
+	}
+	return subject
+}
+
+// IsChange checks if the entry modified the system (Created, Updated or Removed).
+func (entry Entry) IsChange() bool {
+	return entry.Status == result.Created || entry.Status == result.Updated || entry.Status == result.Removed
+}
+
+// Result converts the entry back into a result.
+func (entry Entry) Result() result.Result {
+	return result.New(entry.Status, entry.Message).WithRestart(entry.Restart).WithDuration(entry.Duration).
+		WithSubject(entry.Subject).WithDiff(entry.Diff)
+}
@@ -0,0 +1,265 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/ini"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// Association between MIME types and applications
+// https://specifications.freedesktop.org/mime-apps-spec/mime-apps-spec-latest.html
+const mimeAppsFile = "mimeapps.list"
+const mimeDefaultSection = "Default Applications"
+const mimeAddedSection = "Added Associations"
+const mimeRemovedSection = "Removed Associations"
+
+// MimeApps is the content of a mimeapps.list file.
+// Values are lists of desktop file IDs (ie firefox.desktop).
+type MimeApps struct {
+	path     string
+	defaults map[string][]string
+	added    map[string][]string
+	removed  map[string][]string
+}
+
+// ReadMimeApps reads a mimeapps.list file.
+// If the file does NOT exist, an empty MimeApps is returned.
+func ReadMimeApps(filePath string) (MimeApps, error) {
+	apps := MimeApps{filePath, nil, nil, nil}
+
+	var err error
+	if apps.defaults, err = readMimeSection(filePath, mimeDefaultSection); err != nil {
+		return apps, err
+	}
+	if apps.added, err = readMimeSection(filePath, mimeAddedSection); err != nil {
+		return apps, err
+	}
+	if apps.removed, err = readMimeSection(filePath, mimeRemovedSection); err != nil {
+		return apps, err
+	}
+	return apps, nil
+}
+
+// Path gets the path of the mimeapps.list file.
+func (apps MimeApps) Path() string {
+	return apps.path
+}
+
+// Defaults gets the default applications for a MIME type, by order of preference.
+func (apps MimeApps) Defaults(mimeType string) []string {
+	return apps.defaults[mimeType]
+}
+
+// Added gets the applications associated to a MIME type.
+func (apps MimeApps) Added(mimeType string) []string {
+	return apps.added[mimeType]
+}
+
+// Removed gets the applications explicitly NOT associated to a MIME type.
+func (apps MimeApps) Removed(mimeType string) []string {
+	return apps.removed[mimeType]
+}
+
+// MimeAppsSearchPath gets the locations of the mimeapps.list files, the most important first.
+// Desktop specific files ($desktop-mimeapps.list) come from $XDG_CURRENT_DESKTOP.
+// Some of these files may NOT exist.
+func MimeAppsSearchPath() []string {
+	var desktops []string
+	for _, desktop := range strings.Split(os.Getenv("XDG_CURRENT_DESKTOP"), ":") {
+		if desktop != "" {
+			desktops = append(desktops, strings.ToLower(desktop))
+		}
+	}
+
+	var dirs []string
+	dirs = append(dirs, ConfigSearchPath()...)
+	for _, dir := range DataSearchPath() {
+		dirs = append(dirs, filepath.Join(dir, "applications"))
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		for _, desktop := range desktops {
+			files = append(files, filepath.Join(dir, desktop+"-"+mimeAppsFile))
+		}
+		files = append(files, filepath.Join(dir, mimeAppsFile))
+	}
+	return files
+}
+
+// DefaultApplication gets the effective default application for a MIME type.
+// The first installed application found in [Default Applications] across the search path is returned,
+// then the first one found in [Added Associations].
+// Applications listed in [Removed Associations] of a file are ignored in this file and the less important ones.
+func DefaultApplication(mimeType string) (string, error) {
+	var all []MimeApps
+	for _, file := range MimeAppsSearchPath() {
+		if exists, err := filesystem.RegularFileExists(file); err != nil || !exists {
+			continue
+		}
+		apps, err := ReadMimeApps(file)
+		if err != nil {
+			return "", err
+		}
+		all = append(all, apps)
+	}
+
+	for _, section := range []string{mimeDefaultSection, mimeAddedSection} {
+		removed := map[string]bool{}
+		for _, apps := range all {
+			for _, desktop := range apps.Removed(mimeType) {
+				removed[desktop] = true
+			}
+
+			candidates := apps.Defaults(mimeType)
+			if section == mimeAddedSection {
+				candidates = apps.Added(mimeType)
+			}
+
+			for _, desktop := range candidates {
+				if !removed[desktop] && IsDesktopFileInstalled(desktop) {
+					return desktop, nil
+				}
+			}
+		}
+	}
+
+	return "", errors.New("No default application found for " + mimeType)
+}
+
+// IsDesktopFileInstalled checks if a desktop file ID can be found in the applications folders.
+func IsDesktopFileInstalled(desktop string) bool {
+	if _, err := FindDataFile(filepath.Join("applications", desktop)); err == nil {
+		return true
+	}
+
+	// Desktop file IDs use '-' where the path uses '/' (ie kde4-foo.desktop is kde4/foo.desktop)
+	if idx := strings.Index(desktop, "-"); idx != -1 {
+		if _, err := FindDataFile(filepath.Join("applications", desktop[:idx], desktop[idx+1:])); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetDefaultApplication sets the default application for a MIME type
+// in the user mimeapps.list file.
+// If the application is already the effective default, nothing is written.
+func SetDefaultApplication(mimeType, desktop string) result.Result {
+	if current, err := DefaultApplication(mimeType); err == nil && current == desktop {
+		return result.NewUnchanged("Default application for " + mimeType + " is already " + desktop)
+	}
+
+	file := ConfigFilePath(mimeAppsFile)
+	apps, err := ReadMimeApps(file)
+	if err != nil {
+		return result.FromError(err, "")
+	}
+
+	previous := apps.Defaults(mimeType)
+	if len(previous) != 0 && previous[0] == desktop {
+		return result.NewInfo("Default application for " + mimeType + " is already " + desktop + " in " + file + " but it is overridden by another file or not installed")
+	}
+
+	value := append([]string{desktop}, withoutDesktop(previous, desktop)...)
+	if res := writeMimeSection(file, mimeDefaultSection, mimeType, value); res.IsFailure() {
+		return res
+	}
+
+	if len(previous) == 0 {
+		return result.NewCreated("Default application for " + mimeType + " set to " + desktop)
+	}
+	return result.NewUpdated("Default application for " + mimeType + " updated from " + previous[0] + " to " + desktop)
+}
+
+// SetDefaultApplications sets the same default application for several MIME types.
+func SetDefaultApplications(desktop string, mimeTypes []string) result.Set {
+	results := result.NewSet(nil, "Default application "+desktop)
+	for _, mimeType := range mimeTypes {
+		results.Add(SetDefaultApplication(mimeType, desktop))
+	}
+	return results
+}
+
+// AddMimeAssociation adds an application to the [Added Associations] of a MIME type
+// in the user mimeapps.list file.
+func AddMimeAssociation(mimeType, desktop string) result.Result {
+	return addToMimeSection(mimeAddedSection, mimeType, desktop)
+}
+
+// RemoveMimeAssociation adds an application to the [Removed Associations] of a MIME type
+// in the user mimeapps.list file.
+func RemoveMimeAssociation(mimeType, desktop string) result.Result {
+	return addToMimeSection(mimeRemovedSection, mimeType, desktop)
+}
+
+func addToMimeSection(section, mimeType, desktop string) result.Result {
+	file := ConfigFilePath(mimeAppsFile)
+
+	values, err := readMimeSection(file, section)
+	if err != nil {
+		return result.FromError(err, "")
+	}
+
+	previous := values[mimeType]
+	for _, cur := range previous {
+		if cur == desktop {
+			return result.NewUnchanged(desktop + " is already in [" + section + "] for " + mimeType)
+		}
+	}
+
+	if res := writeMimeSection(file, section, mimeType, append(previous, desktop)); res.IsFailure() {
+		return res
+	}
+
+	if len(previous) == 0 {
+		return result.NewCreated(desktop + " added to [" + section + "] for " + mimeType)
+	}
+	return result.NewUpdated(desktop + " added to [" + section + "] for " + mimeType)
+}
+
+func readMimeSection(file, section string) (map[string][]string, error) {
+	values, err := ini.GetSection(file, section)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := map[string][]string{}
+	for key, value := range values {
+		for _, desktop := range strings.Split(value, ";") {
+			if desktop = strings.TrimSpace(desktop); desktop != "" {
+				lists[key] = append(lists[key], desktop)
+			}
+		}
+	}
+	return lists, nil
+}
+
+func writeMimeSection(file, section, mimeType string, desktops []string) result.Result {
+	value := strings.Join(desktops, ";") + ";"
+	if _, err := ini.SetValue(file, section, mimeType, value, false, false); err != nil {
+		return result.FromError(err, "Can't write "+mimeType+" in ["+section+"] of "+file)
+	}
+	return result.NewUpdated(file + " updated")
+}
+
+func withoutDesktop(desktops []string, desktop string) []string {
+	var list []string
+	for _, cur := range desktops {
+		if cur != desktop {
+			list = append(list, cur)
+		}
+	}
+	return list
+}
@@ -0,0 +1,358 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/result"
+	"github.com/gandrille/go-commons/strpair"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// XDG user directories are described in $XDG_CONFIG_HOME/user-dirs.dirs
+// https://www.freedesktop.org/wiki/Software/xdg-user-dirs/
+// Keys are the ones used by xdg-user-dir (DESKTOP, DOWNLOAD, DOCUMENTS,...)
+const userDirsFile = "user-dirs.dirs"
+const userDirsLocaleFile = "user-dirs.locale"
+const userDirsDefaultsFile = "user-dirs.defaults"
+
+// ReadXdgUserDirs reads all the XDG user directories at once.
+// Keys are returned without the XDG_ prefix and _DIR suffix (ie DESKTOP),
+// values are absolute paths with $HOME expanded.
+// If the file does NOT exist, an empty map is returned.
+func ReadXdgUserDirs() (map[string]string, error) {
+	file := ConfigFilePath(userDirsFile)
+	content, err := filesystem.ReadFileAsStringOrEmptyIfNotExists(file)
+	if err != nil {
+		return nil, errors.New("Can't read " + file + ": " + err.Error())
+	}
+
+	dirs := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if key, value, ok := parseUserDirsLine(line); ok {
+			dirs[key] = value
+		}
+	}
+	return dirs, nil
+}
+
+// ReadXdgUserDirsLocale reads the locale used when the XDG user directories were created.
+// Returns an empty string if the file does NOT exist.
+func ReadXdgUserDirsLocale() (string, error) {
+	file := ConfigFilePath(userDirsLocaleFile)
+	content, err := filesystem.ReadFileAsStringOrEmptyIfNotExists(file)
+	if err != nil {
+		return "", errors.New("Can't read " + file + ": " + err.Error())
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// ReadXdgUserDirsDefaults reads the system wide default XDG user directories,
+// from the first user-dirs.defaults file found in $XDG_CONFIG_DIRS.
+// Keys are the same as for ReadXdgUserDirs, values are absolute paths.
+// If no file is found, an empty map is returned.
+func ReadXdgUserDirsDefaults() (map[string]string, error) {
+	dirs := map[string]string{}
+
+	file, err := findFile(ConfigDirs(), userDirsDefaultsFile)
+	if err != nil {
+		return dirs, nil
+	}
+
+	content, err := filesystem.ReadFileAsString(file)
+	if err != nil {
+		return nil, errors.New("Can't read " + file + ": " + err.Error())
+	}
+
+	homeDir := filesystem.HomeDir()
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.Index(line, "=")
+		if sep == -1 {
+			continue
+		}
+		key := strings.ToUpper(strings.TrimSpace(line[:sep]))
+		value := strings.TrimSpace(line[sep+1:])
+		if filepath.IsAbs(value) {
+			dirs[key] = filepath.Clean(value)
+		} else {
+			dirs[key] = filepath.Join(homeDir, value)
+		}
+	}
+	return dirs, nil
+}
+
+// UpdateXdgUserDirs relocates a batch of XDG user directories.
+// Each pair is made of a key (ie DOWNLOAD) and the new location, which can use $HOME, $HOSTNAME or ~.
+// The user-dirs.dirs file is written once, keeping comments and unrelated keys.
+// If migrate == true, the content of the previous folder is moved to the new one first,
+// and a key is NOT updated if its content can't be migrated.
+// Otherwise, the previous folder is only removed if it is empty.
+func UpdateXdgUserDirs(dirs []strpair.StrPair, migrate bool) result.Set {
+	results := result.NewSet(nil, "XDG user directories")
+
+	file := ConfigFilePath(userDirsFile)
+	content, err := filesystem.ReadFileAsStringOrEmptyIfNotExists(file)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't read "+file))
+		return results
+	}
+	lines := strings.Split(content, "\n")
+
+	type relocation struct{ key, src, dst string }
+	var relocations []relocation
+
+	for _, pair := range dirs {
+		key := strings.ToUpper(pair.Str1())
+		dst := normalizeUserDir(pair.Str2())
+
+		idx, src := findUserDirsLine(lines, key)
+		if idx != -1 && src == dst {
+			results.Add(result.NewUnchanged("XDG dir " + key + " already has value " + dst))
+			continue
+		}
+
+		if migrate && src != "" {
+			if err := checkMigration(src, dst); err != nil {
+				results.Add(result.NewError("XDG dir " + key + " NOT updated, its content can't be migrated: " + err.Error()))
+				continue
+			}
+			failed := false
+			for _, res := range migrateFolder(src, dst) {
+				results.Add(res)
+				failed = failed || res.IsFailure()
+			}
+			if failed {
+				results.Add(result.NewSkipped("XDG dir " + key + " NOT updated, it still points to " + src))
+				continue
+			}
+		}
+
+		if idx == -1 {
+			lines = appendUserDirsLine(lines, formatUserDirsLine(key, dst))
+			results.Add(result.NewCreated("XDG dir " + key + " initialized with " + dst))
+		} else {
+			lines[idx] = formatUserDirsLine(key, dst)
+			results.Add(result.NewUpdated("XDG dir " + key + " updated from " + src + " to " + dst))
+		}
+		relocations = append(relocations, relocation{key, src, dst})
+	}
+
+	// Nothing to write
+	if len(relocations) == 0 {
+		return results
+	}
+
+	newContent := strings.Join(lines, "\n")
+	if res := filesystem.WriteStringFile(file, newContent, true); res.IsFailure() {
+		results.Add(res)
+		return results
+	}
+
+	for _, r := range relocations {
+		if r.src != "" && !migrate {
+			results.Add(removeFolderIfEmpty(r.src))
+		}
+		if res := filesystem.CreateFolderIfNeeded(r.dst); !res.IsUnchanged() {
+			results.Add(res)
+		}
+	}
+
+	return results
+}
+
+// normalizeUserDir expands $HOME, $HOSTNAME and ~ in a folder location.
+func normalizeUserDir(value string) string {
+	homeDir := filesystem.HomeDir()
+	value = strings.Replace(value, "$HOME", homeDir, -1)
+	value = strings.Replace(value, "${HOME}", homeDir, -1)
+	value = strings.Replace(value, "$HOSTNAME", Hostname(), -1)
+	if strings.HasPrefix(value, "~") {
+		value = strings.Replace(value, "~", homeDir, 1)
+	}
+	return filepath.Clean(value + "/")
+}
+
+// parseUserDirsLine parses a XDG_xxx_DIR="$HOME/yyy" line.
+func parseUserDirsLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	sep := strings.Index(line, "=")
+	if sep == -1 {
+		return "", "", false
+	}
+
+	name := strings.TrimSpace(line[:sep])
+	if !strings.HasPrefix(name, "XDG_") || !strings.HasSuffix(name, "_DIR") || len(name) <= len("XDG__DIR") {
+		return "", "", false
+	}
+	key := name[len("XDG_") : len(name)-len("_DIR")]
+
+	value := strings.TrimSpace(line[sep+1:])
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		value = value[1 : len(value)-1]
+	}
+	value = shellUnescape(value)
+
+	return key, normalizeUserDir(value), true
+}
+
+// formatUserDirsLine builds a line using the format expected by xdg-user-dirs.
+// Paths inside the home directory are written relative to $HOME.
+func formatUserDirsLine(key, dir string) string {
+	homeDir := filesystem.HomeDir()
+
+	var value string
+	if dir == homeDir {
+		value = "$HOME/"
+	} else if strings.HasPrefix(dir, homeDir+"/") {
+		value = "$HOME/" + shellEscape(strings.TrimPrefix(dir, homeDir+"/"))
+	} else {
+		value = shellEscape(dir)
+	}
+
+	return "XDG_" + key + "_DIR=\"" + value + "\""
+}
+
+// findUserDirsLine returns the index of the line defining a key and its value,
+// or -1 if the key is not defined.
+func findUserDirsLine(lines []string, key string) (int, string) {
+	for i, line := range lines {
+		if curKey, curValue, ok := parseUserDirsLine(line); ok && curKey == key {
+			return i, curValue
+		}
+	}
+	return -1, ""
+}
+
+// appendUserDirsLine appends a line, keeping the trailing newline at the end of the file.
+func appendUserDirsLine(lines []string, line string) []string {
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		return append(lines[:len(lines)-1], line, "")
+	}
+	return append(lines, line, "")
+}
+
+func shellEscape(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "$", "\\$", "`", "\\`")
+	return replacer.Replace(value)
+}
+
+func shellUnescape(value string) string {
+	var sb strings.Builder
+	escaped := false
+	for _, c := range value {
+		if !escaped && c == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// removeFolderIfEmpty removes a previous XDG folder if it is empty.
+func removeFolderIfEmpty(folderPath string) result.Result {
+	if folderPath == filesystem.HomeDir() {
+		return result.NewSkipped("Folder " + folderPath + " is the home directory and was NOT removed")
+	}
+
+	str, err := filesystem.IsEmptyFolder(folderPath)
+	switch {
+	case err != nil:
+		return result.FromError(err, "Error while checking if "+folderPath+" is empty")
+	case str == "NOT_EXIST":
+		return result.NewUnchanged("Folder " + folderPath + " does NOT exist")
+	case str == "NOT_FOLDER":
+		return result.NewWarning(folderPath + " was NOT removed because it is not a folder")
+	case str == "NOT_EMPTY":
+		return result.NewWarning("Folder " + folderPath + " was NOT removed because it is not empty")
+	}
+
+	if err := os.Remove(folderPath); err != nil {
+		return result.FromError(err, "Empty folder "+folderPath+" was NOT removed")
+	}
+	return result.NewRemoved("Empty folder " + folderPath + " removed")
+}
+
+// checkMigration checks that the content of src can be moved into dst.
+func checkMigration(src, dst string) error {
+	if src == filesystem.HomeDir() {
+		return errors.New(src + " is the home directory")
+	}
+
+	// A folder can't be moved inside itself
+	if rel, err := filepath.Rel(src, dst); err != nil || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))) {
+		return errors.New(dst + " is inside " + src)
+	}
+	return nil
+}
+
+// migrateFolder moves the content of src into dst, then removes src.
+// Elements already present in dst are left in src.
+// The migration MUST have been checked with checkMigration.
+func migrateFolder(src, dst string) []result.Result {
+	if exists, err := filesystem.FolderExists(src); err != nil {
+		return []result.Result{result.FromError(err, "Can't migrate "+src)}
+	} else if !exists {
+		return []result.Result{result.NewUnchanged("Folder " + src + " does NOT exist, nothing to migrate")}
+	}
+
+	// Destination does NOT exist: the folder can be moved as a whole
+	if exists, err := filesystem.Exists(dst); err != nil {
+		return []result.Result{result.FromError(err, "Can't check if "+dst+" exists")}
+	} else if !exists {
+		if err := os.MkdirAll(filepath.Dir(dst), 0775); err != nil {
+			return []result.Result{result.FromError(err, "Can't create parent folder of "+dst)}
+		}
+		if err := os.Rename(src, dst); err == nil {
+			return []result.Result{result.NewUpdated("Folder " + src + " moved to " + dst)}
+		}
+	}
+
+	// Destination exists: moving elements one by one
+	if res := filesystem.CreateFolderIfNeeded(dst); res.IsFailure() {
+		return []result.Result{res}
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return []result.Result{result.FromError(err, "Can't read "+src+" content")}
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return []result.Result{result.FromError(err, "Can't read "+src+" content")}
+	}
+	sort.Strings(names)
+
+	var results []result.Result
+	for _, name := range names {
+		from := filepath.Join(src, name)
+		to := filepath.Join(dst, name)
+		if _, err := os.Lstat(to); err == nil {
+			results = append(results, result.NewWarning(from+" NOT moved because "+to+" already exists"))
+		} else if err := os.Rename(from, to); err != nil {
+			results = append(results, result.FromError(err, "Can't move "+from+" to "+to))
+		} else {
+			results = append(results, result.NewUpdated(from+" moved to "+to))
+		}
+	}
+
+	return append(results, removeFolderIfEmpty(src))
+}
@@ -2,13 +2,13 @@ package env
 
 import (
 	"errors"
-	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/result"
+	"github.com/gandrille/go-commons/strpair"
 )
 
 // IMPORTANT! READ ME FIRST!
@@ -18,7 +18,6 @@ import (
 
 const xdgExec = "/usr/bin/xdg-settings"
 const xdgRead = "/usr/bin/xdg-user-dir"
-const xdgUpdate = "/usr/bin/xdg-user-dirs-update"
 
 // ReadXdgSettings Reads an XDG settings
 func ReadXdgSettings(key string) (string, error) {
@@ -77,53 +76,20 @@ func ReadXdgDir(key string) (string, error) {
 }
 
 // UpdateXdgDir Updates the location of an XDG directory
-// It updates the ~/.config/user-dirs.dirs file using XDG executables
+// Returns true if the location has been changed.
+//
+// Deprecated: use EnsureXdgDir, which also reports what happened to the previous and the new folders.
 func UpdateXdgDir(key, value string) (bool, error) {
-
-	hostName := Hostname()
-	homeDir := filesystem.HomeDir()
-
-	// Read
-	src, errReadXdgKey := ReadXdgDir(key)
-	if errReadXdgKey != nil {
-		return false, errReadXdgKey
-	}
-
-	// Normalize dest
-	dst := filepath.Clean(strings.Replace(strings.Replace(value, "$HOME", homeDir, -1), "$HOSTNAME", hostName, -1) + "/")
-
-	// Is update needed ?
-	if src == dst {
-		return false, nil
-	}
-
-	// Check if executable exists
-	if exists, err := filesystem.RegularFileExists(xdgUpdate); err != nil || exists == false {
-		return false, errors.New("File " + xdgUpdate + " does NOT exist")
-	}
-
-	// Write new value
-	if err := exec.Command(xdgUpdate, "--set", key, dst).Run(); err != nil {
-		return false, errors.New("Can't write xdg dir " + key + ": " + err.Error())
-	}
-
-	// Remove src directory if needed
-	if str, err := filesystem.IsEmptyFolder(src); err != nil {
-		fmt.Println("[WARNING] Error while checking if " + src + " is empty: " + err.Error())
-	} else if str == "EMPTY" {
-		if err := os.Remove(src); err != nil {
-			fmt.Println("[WARNING] empty folder " + src + " was NOT removed: " + err.Error())
-		} else {
-			fmt.Println("[UPDATED] empty folder " + src + " removed")
-		}
-	} else {
-		fmt.Println("[WARNING] folder " + src + " was NOT removed because it is not empty")
-	}
-
-	// Create destination if needed
-	if result := filesystem.CreateFolderIfNeeded(dst); !result.IsSuccess() {
-		fmt.Println("[WARNING] Error creating " + dst + " " + result.Message())
+	results := EnsureXdgDir(key, value)
+	if err := results.Err(); err != nil {
+		return false, err
 	}
+	return results.Count(result.Created)+results.Count(result.Updated) != 0, nil
+}
 
-	return true, nil
+// EnsureXdgDir Updates the location of an XDG directory
+// It updates the ~/.config/user-dirs.dirs file (see UpdateXdgUserDirs),
+// the previous folder is removed if it is empty.
+func EnsureXdgDir(key, value string) result.Set {
+	return UpdateXdgUserDirs([]strpair.StrPair{strpair.New(key, value)}, false)
 }
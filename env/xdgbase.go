@@ -0,0 +1,177 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gandrille/go-commons/filesystem"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// XDG Base Directory Specification
+// https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
+// Relative paths found in environment variables are invalid and ignored.
+// A leading ~ is expanded to the home directory.
+
+// ConfigHome gets the base directory for user specific configuration files.
+// $XDG_CONFIG_HOME, defaults to ~/.config
+func ConfigHome() string {
+	return baseDir("XDG_CONFIG_HOME", ".config")
+}
+
+// DataHome gets the base directory for user specific data files.
+// $XDG_DATA_HOME, defaults to ~/.local/share
+func DataHome() string {
+	return baseDir("XDG_DATA_HOME", ".local/share")
+}
+
+// CacheHome gets the base directory for user specific non-essential (cached) data.
+// $XDG_CACHE_HOME, defaults to ~/.cache
+func CacheHome() string {
+	return baseDir("XDG_CACHE_HOME", ".cache")
+}
+
+// StateHome gets the base directory for user specific state data (history, logs,...).
+// $XDG_STATE_HOME, defaults to ~/.local/state
+func StateHome() string {
+	return baseDir("XDG_STATE_HOME", ".local/state")
+}
+
+// RuntimeDir gets the base directory for user specific runtime files (sockets, named pipes,...).
+// $XDG_RUNTIME_DIR has no default value: an error is returned if it is not set.
+func RuntimeDir() (string, error) {
+	value := expandBaseDir(os.Getenv("XDG_RUNTIME_DIR"))
+	if value == "" {
+		return "", errors.New("XDG_RUNTIME_DIR is not set or is not an absolute path")
+	}
+	return value, nil
+}
+
+// ConfigDirs gets the preference ordered set of base directories to search for configuration files,
+// in addition to ConfigHome.
+// $XDG_CONFIG_DIRS, defaults to /etc/xdg
+func ConfigDirs() []string {
+	return baseDirs("XDG_CONFIG_DIRS", "/etc/xdg")
+}
+
+// DataDirs gets the preference ordered set of base directories to search for data files,
+// in addition to DataHome.
+// $XDG_DATA_DIRS, defaults to /usr/local/share:/usr/share
+func DataDirs() []string {
+	return baseDirs("XDG_DATA_DIRS", "/usr/local/share:/usr/share")
+}
+
+// ConfigSearchPath gets ConfigHome followed by ConfigDirs.
+func ConfigSearchPath() []string {
+	return append([]string{ConfigHome()}, ConfigDirs()...)
+}
+
+// DataSearchPath gets DataHome followed by DataDirs.
+func DataSearchPath() []string {
+	return append([]string{DataHome()}, DataDirs()...)
+}
+
+// FindConfigFile finds the most important existing configuration file across the search path.
+// relPath is relative to the base directories (ie "myapp/config.ini").
+func FindConfigFile(relPath string) (string, error) {
+	return findFile(ConfigSearchPath(), relPath)
+}
+
+// FindDataFile finds the most important existing data file across the search path.
+// relPath is relative to the base directories (ie "applications/myapp.desktop").
+func FindDataFile(relPath string) (string, error) {
+	return findFile(DataSearchPath(), relPath)
+}
+
+// FindConfigFiles gets all the existing configuration files across the search path,
+// the most important first.
+func FindConfigFiles(relPath string) []string {
+	return findFiles(ConfigSearchPath(), relPath)
+}
+
+// FindDataFiles gets all the existing data files across the search path,
+// the most important first.
+func FindDataFiles(relPath string) []string {
+	return findFiles(DataSearchPath(), relPath)
+}
+
+// ConfigFilePath gets the path where a user configuration file should be written.
+func ConfigFilePath(relPath string) string {
+	return filepath.Join(ConfigHome(), relPath)
+}
+
+// DataFilePath gets the path where a user data file should be written.
+func DataFilePath(relPath string) string {
+	return filepath.Join(DataHome(), relPath)
+}
+
+// CacheFilePath gets the path where a user cache file should be written.
+func CacheFilePath(relPath string) string {
+	return filepath.Join(CacheHome(), relPath)
+}
+
+// StateFilePath gets the path where a user state file should be written.
+func StateFilePath(relPath string) string {
+	return filepath.Join(StateHome(), relPath)
+}
+
+func baseDir(variable, defaultRelPath string) string {
+	if value := expandBaseDir(os.Getenv(variable)); value != "" {
+		return value
+	}
+	return filepath.Join(filesystem.HomeDir(), defaultRelPath)
+}
+
+func baseDirs(variable, defaultValue string) []string {
+	value := os.Getenv(variable)
+	if value == "" {
+		value = defaultValue
+	}
+
+	var dirs []string
+	for _, dir := range strings.Split(value, ":") {
+		if dir = expandBaseDir(dir); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	if len(dirs) == 0 {
+		return strings.Split(defaultValue, ":")
+	}
+	return dirs
+}
+
+// expandBaseDir expands a leading ~ and returns an empty string for relative paths.
+func expandBaseDir(dir string) string {
+	if strings.HasPrefix(dir, "~") {
+		dir = strings.Replace(dir, "~", filesystem.HomeDir(), 1)
+	}
+	if !filepath.IsAbs(dir) {
+		return ""
+	}
+	return filepath.Clean(dir)
+}
+
+func findFile(searchPath []string, relPath string) (string, error) {
+	if files := findFiles(searchPath, relPath); len(files) != 0 {
+		return files[0], nil
+	}
+	return "", errors.New("File " + relPath + " NOT found in " + strings.Join(searchPath, ":"))
+}
+
+func findFiles(searchPath []string, relPath string) []string {
+	var files []string
+	for _, dir := range searchPath {
+		file := filepath.Join(dir, relPath)
+		if exists, err := filesystem.RegularFileExists(file); err == nil && exists {
+			files = append(files, file)
+		}
+	}
+	return files
+}
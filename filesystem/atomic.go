@@ -0,0 +1,75 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AtomicFile is a temporary file written next to a target file, then moved over it,
+// so that the target is never left partially written.
+type AtomicFile struct {
+	*os.File
+	target    string
+	closed    bool
+	committed bool
+}
+
+// CreateAtomicFile creates a temporary file in the folder of a target file, creating the folder if needed.
+// Write the content, then call Commit. Abort MUST be deferred to remove the temporary file on failure.
+func CreateAtomicFile(target string) (*AtomicFile, error) {
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(target)+".")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{file, target, false, false}, nil
+}
+
+// Target getter.
+func (file *AtomicFile) Target() string {
+	return file.target
+}
+
+// Commit flushes the content on disk, sets the mode (and the modification time if NOT zero),
+// then replaces the target. A symlink target is replaced, NOT the file it points to.
+func (file *AtomicFile) Commit(mode os.FileMode, modTime time.Time) error {
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	file.closed = true
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		if err := os.Chtimes(file.Name(), modTime, modTime); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(file.Name(), file.target); err != nil {
+		return err
+	}
+	file.committed = true
+	return nil
+}
+
+// Abort removes the temporary file. It does nothing once the target has been replaced.
+func (file *AtomicFile) Abort() {
+	if file.committed {
+		return
+	}
+	if !file.closed {
+		file.Close()
+		file.closed = true
+	}
+	os.Remove(file.Name())
+}
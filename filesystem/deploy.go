@@ -0,0 +1,335 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// DefaultManifestFile is the name of the manifest of the deployed files, inside the destination folder.
+const DefaultManifestFile = ".deploy-manifest.json"
+
+// ModifiedPolicy tells what to do with the deployed files modified by the user.
+type ModifiedPolicy int
+
+const (
+	KeepModified      ModifiedPolicy = iota // the file modified by the user is kept, with a Warning
+	BackupModified                          // the file modified by the user is renamed (file.YYYYMMDD-hhmmss.bak), then replaced
+	OverwriteModified                       // the file modified by the user is replaced
+)
+
+// DeployOptions configures Deploy.
+type DeployOptions struct {
+	Manifest string         // manifest of the deployed files, DefaultManifestFile inside the destination folder if empty
+	Modified ModifiedPolicy // what to do with the files modified by the user
+	FileMode os.FileMode    // mode of the deployed files, the source mode with owner write permission (or 0644) if zero
+}
+
+// deployManifest lists the deployed files, with the hash of their content (relative slash separated path -> hash).
+type deployManifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// Deploy installs all the files of a tree (ie an embed.FS or an archive) into a destination folder.
+// The deployed files are recorded in a manifest, with the hash of their content, so that the next deployment
+//   - updates the files which have NOT been modified by the user,
+//   - applies options.Modified to the files modified by the user (or existing before the first deployment),
+//   - removes the files which are no longer part of the source (unless modified by the user).
+func Deploy(source fs.FS, dest string, options DeployOptions) result.Set {
+	dest = filepath.Clean(strings.Replace(dest, "~", HomeDir(), 1))
+	results := result.NewSet(nil, "Deployment into "+strings.Replace(dest, HomeDir(), "~", 1))
+
+	manifestPath := options.Manifest
+	if manifestPath == "" {
+		manifestPath = filepath.Join(dest, DefaultManifestFile)
+	}
+	manifestPath = strings.Replace(manifestPath, "~", HomeDir(), 1)
+
+	previous, err := readDeployManifest(manifestPath)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't read manifest "+manifestPath))
+		return results
+	}
+	current := deployManifest{map[string]string{}}
+
+	// Deploy the files of the source
+	err = fs.WalkDir(source, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() {
+			return nil
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		res, hash := deployFile(source, name, target, previous.Files[name], options)
+		results.Add(res.WithSubject(target))
+		if hash != "" {
+			current.Files[name] = hash
+		}
+		return nil
+	})
+	if err != nil {
+		results.Add(result.FromError(err, "Can't read the files to deploy"))
+		return results
+	}
+
+	// Remove the files which are no longer part of the source
+	var removed []string
+	for name := range previous.Files {
+		if _, found := current.Files[name]; !found && fs.ValidPath(name) {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		res, kept := pruneFile(target, dest, previous.Files[name], options)
+		if !res.IsUnchanged() {
+			results.Add(res.WithSubject(target))
+		}
+		if kept {
+			current.Files[name] = previous.Files[name]
+		}
+	}
+
+	if err := writeDeployManifest(manifestPath, current); err != nil {
+		results.Add(result.FromError(err, "Can't write manifest "+manifestPath))
+	}
+	return results
+}
+
+// deployFile installs a file.
+// Returns the hash to record in the manifest, or an empty string if the file is NOT managed.
+func deployFile(source fs.FS, name, target, managedHash string, options DeployOptions) (result.Result, string) {
+	fileName := strings.Replace(target, HomeDir(), "~", 1)
+
+	info, err := fs.Stat(source, name)
+	if err != nil {
+		return result.FromError(err, "Can't read "+name), managedHash
+	}
+	mode := options.FileMode
+	if mode == 0 {
+		mode = info.Mode().Perm() | 0200
+	}
+	if mode == 0200 {
+		mode = 0644
+	}
+
+	hash, err := hashFSFile(source, name)
+	if err != nil {
+		return result.FromError(err, "Can't read "+name), managedHash
+	}
+
+	exists, err := RegularFileExists(target)
+	if err != nil {
+		return result.NewError(fileName + " exists but is not a regular file"), managedHash
+	}
+
+	// New file
+	if !exists {
+		if err := copyFSFile(source, name, target, mode); err != nil {
+			return result.FromError(err, fileName+" writing error"), managedHash
+		}
+		return result.NewCreated(fileName + " created"), hash
+	}
+
+	curHash, err := hashFile(target)
+	if err != nil {
+		return result.FromError(err, fileName+" already exists but we can't read its content"), managedHash
+	}
+
+	// Up to date file
+	if curHash == hash {
+		curInfo, err := os.Stat(target)
+		if err != nil {
+			return result.FromError(err, "Can't read mode of "+fileName), hash
+		}
+		if curInfo.Mode().Perm() == mode {
+			return result.NewUnchanged(fileName + " already has expected content"), hash
+		}
+		if err := os.Chmod(target, mode); err != nil {
+			return result.FromError(err, "Can't update mode of "+fileName), hash
+		}
+		return result.NewUpdated(fileName + " mode updated to " + mode.String()), hash
+	}
+
+	// File modified by the user, or existing before the first deployment
+	backup := ""
+	if curHash != managedHash {
+		switch options.Modified {
+		case KeepModified:
+			return result.NewWarning(fileName + " has been modified by the user: it is kept"), managedHash
+		case BackupModified:
+			if backup, err = backupFile(target); err != nil {
+				return result.FromError(err, "Can't backup "+fileName), managedHash
+			}
+		}
+	}
+
+	if err := copyFSFile(source, name, target, mode); err != nil {
+		return result.FromError(err, "Can't update "+fileName), managedHash
+	}
+	if backup != "" {
+		return result.NewUpdated(fileName + " updated, the user version is saved as " + filepath.Base(backup)), hash
+	}
+	return result.NewUpdated(fileName + " updated"), hash
+}
+
+// pruneFile removes a file which is no longer part of the source, and its empty parent folders.
+// Returns an Unchanged result if the file has already been removed,
+// and true if the file is kept (and therefore still managed).
+func pruneFile(target, dest, managedHash string, options DeployOptions) (result.Result, bool) {
+	fileName := strings.Replace(target, HomeDir(), "~", 1)
+
+	exists, err := RegularFileExists(target)
+	if err != nil || !exists {
+		return result.NewUnchanged(fileName + " already removed"), false
+	}
+
+	curHash, err := hashFile(target)
+	if err != nil {
+		return result.FromError(err, fileName+" can't be read"), true
+	}
+
+	backup := ""
+	if curHash != managedHash {
+		switch options.Modified {
+		case KeepModified:
+			return result.NewWarning(fileName + " is no longer deployed but it has been modified by the user: it is kept"), false
+		case BackupModified:
+			if backup, err = backupFile(target); err != nil {
+				return result.FromError(err, "Can't backup "+fileName), true
+			}
+		}
+	}
+
+	if backup == "" {
+		if err := os.Remove(target); err != nil {
+			return result.FromError(err, "Can't remove "+fileName), true
+		}
+	}
+
+	// Empty parent folders are removed as well
+	for dir := filepath.Dir(target); dir != dest && strings.HasPrefix(dir, dest); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	if backup != "" {
+		return result.NewRemoved(fileName + " removed, the user version is saved as " + filepath.Base(backup)), false
+	}
+	return result.NewRemoved(fileName + " removed"), false
+}
+
+// =============================================
+
+func readDeployManifest(manifestPath string) (deployManifest, error) {
+	manifest := deployManifest{map[string]string{}}
+
+	content, err := ioutil.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, err
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]string{}
+	}
+	return manifest, nil
+}
+
+func writeDeployManifest(manifestPath string, manifest deployManifest) error {
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The manifest is written next to the current one, then moved,
+	// so that an interrupted deployment does NOT corrupt it
+	tmp, err := CreateAtomicFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	defer tmp.Abort()
+
+	if _, err := tmp.Write(append(content, '\n')); err != nil {
+		return err
+	}
+	return tmp.Commit(0644, time.Time{})
+}
+
+// copyFSFile writes a file next to the target, then moves it.
+func copyFSFile(source fs.FS, name, target string, mode os.FileMode) error {
+	in, err := source.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := CreateAtomicFile(target)
+	if err != nil {
+		return err
+	}
+	defer tmp.Abort()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	return tmp.Commit(mode, time.Time{})
+}
+
+// backupFile renames a file as file.YYYYMMDD-hhmmss.bak.
+func backupFile(target string) (string, error) {
+	backup := target + "." + time.Now().Format("20060102-150405") + ".bak"
+	if _, err := os.Lstat(backup); err == nil {
+		return "", errors.New(backup + " already exists")
+	}
+	return backup, os.Rename(target, backup)
+}
+
+func hashFSFile(source fs.FS, name string) (string, error) {
+	file, err := source.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashReader(file)
+}
+
+func hashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return hashReader(file)
+}
+
+func hashReader(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
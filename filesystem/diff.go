@@ -0,0 +1,109 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// maxDiffCells limits the memory used for computing a diff (lines of old content * lines of new content).
+const maxDiffCells = 4 * 1024 * 1024
+
+// TextDiff describes the changes between two texts, line by line.
+// Removed lines are prefixed by "-", added ones by "+", unchanged lines are omitted.
+// Large texts are NOT compared, a summary is returned instead.
+// The content of the texts is disclosed: use DiffSummary for sensitive files.
+func TextDiff(oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	diff, removed, added := lineDiff(oldContent, newContent)
+	if diff == nil && removed+added != 0 {
+		return "content replaced: " + strconv.Itoa(removed) + " lines removed, " + strconv.Itoa(added) + " lines added"
+	}
+	if len(diff) == 0 {
+		return "newline at end of file changed"
+	}
+	return strings.Join(diff, "\n")
+}
+
+// DiffSummary describes the changes between two texts without disclosing their content:
+// the number of removed and added lines, then the size and the SHA-256 hash of the new text.
+func DiffSummary(oldContent, newContent string) string {
+	if oldContent == newContent {
+		return ""
+	}
+	_, removed, added := lineDiff(oldContent, newContent)
+	hash := sha256.Sum256([]byte(newContent))
+	return strconv.Itoa(removed) + " lines removed, " + strconv.Itoa(added) + " lines added, " +
+		strconv.Itoa(len(newContent)) + " bytes, sha256 " + hex.EncodeToString(hash[:])
+}
+
+// lineDiff computes the removed ("-" prefix) and added ("+" prefix) lines, and counts them.
+// For large texts, only the lines are counted (the diff is nil).
+func lineDiff(oldContent, newContent string) ([]string, int, int) {
+	oldLines := splitLines(oldContent)
+	newLines := splitLines(newContent)
+
+	// common prefix and suffix are skipped
+	start := 0
+	for start < len(oldLines) && start < len(newLines) && oldLines[start] == newLines[start] {
+		start++
+	}
+	oldEnd, newEnd := len(oldLines), len(newLines)
+	for oldEnd > start && newEnd > start && oldLines[oldEnd-1] == newLines[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	oldLines = oldLines[start:oldEnd]
+	newLines = newLines[start:newEnd]
+
+	if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+		return nil, len(oldLines), len(newLines)
+	}
+
+	// longest common subsequence, lcs[i][j] being the one of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	removed, added := 0, 0
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, "-"+oldLines[i])
+			removed++
+			i++
+		default:
+			diff = append(diff, "+"+newLines[j])
+			added++
+			j++
+		}
+	}
+	return diff, removed, added
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
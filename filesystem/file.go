@@ -3,6 +3,7 @@ package filesystem
 import (
 	"bytes"
 	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -124,48 +125,64 @@ func ReadFileAsStringOrEmptyIfNotExists(filePath string) (string, error) {
 
 // WriteStringFile creates a file and writes the content of a string into it.
 // if overwrite  == true, replaces the file content if the file exists.
-func WriteStringFile(filePath, newContent string, overwrite bool) result.Result {
+// The optional attributes (mode, owner) are applied even if the content is left unchanged.
+// The result subject is the file path. If the file is written, the result diff is a summary of the changes
+// which does NOT disclose the content (see DiffSummary, use TextDiff and WithDiff for a full diff).
+func WriteStringFile(filePath, newContent string, overwrite bool, attrs ...FileAttributes) result.Result {
 	fileName := strings.Replace(filePath, HomeDir(), "~", 1)
 	filePath = strings.Replace(filePath, "~", HomeDir(), 1)
+	return writeStringFile(filePath, fileName, newContent, overwrite, attrs).WithSubject(filePath)
+}
 
+func writeStringFile(filePath, fileName, newContent string, overwrite bool, attrs []FileAttributes) result.Result {
 	// Check if file exists
 	exists, errExists := RegularFileExists(filePath)
 	if errExists != nil {
-		return result.NewError(errExists.Error())
+		return result.FromError(errExists, "")
 	}
 
 	// The file does NOT exist
 	if !exists {
-		if err := replaceStringFileContent(filePath, newContent); err != nil {
-			return result.NewError(fileName + " writing error: " + err.Error())
+		if err := replaceStringFileContent(filePath, newContent, attrs...); err != nil {
+			return result.FromError(err, fileName+" writing error")
+		} else if _, err := applyAttributes(filePath, attrs); err != nil {
+			return result.FromError(err, fileName+" created but attributes can't be set")
 		} else {
-			return result.NewCreated(fileName + " created")
+			return result.NewCreated(fileName + " created").WithDiff(DiffSummary("", newContent))
 		}
 	}
 
 	// The file exists
 	curContent, err := ReadFileAsString(filePath)
 	if err != nil {
-		return result.NewError(fileName + " already exists but we can't read its content: " + err.Error())
+		return result.FromError(err, fileName+" already exists but we can't read its content")
 	}
 	if curContent == newContent {
-		return result.NewUnchanged(fileName + " already has expected content")
+		return withAttributes(filePath, fileName, attrs, result.NewUnchanged(fileName+" already has expected content"))
 	}
 	if overwrite {
-		if err := replaceStringFileContent(filePath, newContent); err != nil {
-			return result.NewError("Can't update " + fileName + ": " + err.Error())
+		if err := replaceStringFileContent(filePath, newContent, attrs...); err != nil {
+			return result.FromError(err, "Can't update "+fileName)
 		}
-		return result.NewUpdated(fileName + " updated")
+		if _, err := applyAttributes(filePath, attrs); err != nil {
+			return result.FromError(err, fileName+" updated but attributes can't be set")
+		}
+		return result.NewUpdated(fileName + " updated").WithDiff(DiffSummary(curContent, newContent))
 	}
-	return result.NewUnchanged(fileName + " user defined content left unchanged")
+	return withAttributes(filePath, fileName, attrs, result.NewUnchanged(fileName+" user defined content left unchanged"))
 }
 
 // WriteBinaryFile creates a file and writes the content of a byte slice into it.
 // if overwrite  == true, replaces the file content if the file exists.
-func WriteBinaryFile(filePath string, newContent []byte, writeIfFileExists bool) result.Result {
+// The optional attributes (mode, owner) are applied even if the content is left unchanged.
+// The result subject is the file path.
+func WriteBinaryFile(filePath string, newContent []byte, writeIfFileExists bool, attrs ...FileAttributes) result.Result {
 	fileName := strings.Replace(filePath, HomeDir(), "~", 1)
 	filePath = strings.Replace(filePath, "~", HomeDir(), 1)
+	return writeBinaryFile(filePath, fileName, newContent, writeIfFileExists, attrs).WithSubject(filePath)
+}
 
+func writeBinaryFile(filePath, fileName string, newContent []byte, writeIfFileExists bool, attrs []FileAttributes) result.Result {
 	// Check if file exists
 	exists, errExists := RegularFileExists(filePath)
 	if errExists != nil {
@@ -174,8 +191,10 @@ func WriteBinaryFile(filePath string, newContent []byte, writeIfFileExists bool)
 
 	// The file does NOT exist
 	if !exists {
-		if err := replaceBinaryFileContent(filePath, newContent); err != nil {
-			return result.NewError(fileName + " writing error: " + err.Error())
+		if err := replaceBinaryFileContent(filePath, newContent, attrs...); err != nil {
+			return result.FromError(err, fileName+" writing error")
+		} else if _, err := applyAttributes(filePath, attrs); err != nil {
+			return result.FromError(err, fileName+" created but attributes can't be set")
 		} else {
 			return result.NewCreated(fileName + " created")
 		}
@@ -184,44 +203,123 @@ func WriteBinaryFile(filePath string, newContent []byte, writeIfFileExists bool)
 	// The file exists
 	curContent, err := ReadFileAsBinary(filePath)
 	if err != nil {
-		return result.NewError(fileName + " already exists but we can't read its content: " + err.Error())
+		return result.FromError(err, fileName+" already exists but we can't read its content")
 	}
 	if bytes.Equal(curContent, newContent) {
-		return result.NewUnchanged(fileName + " already has expected content")
+		return withAttributes(filePath, fileName, attrs, result.NewUnchanged(fileName+" already has expected content"))
 	}
 	if writeIfFileExists {
-		if err := replaceBinaryFileContent(filePath, newContent); err != nil {
-			return result.NewError("Can't update " + fileName + ": " + err.Error())
+		if err := replaceBinaryFileContent(filePath, newContent, attrs...); err != nil {
+			return result.FromError(err, "Can't update "+fileName)
+		}
+		if _, err := applyAttributes(filePath, attrs); err != nil {
+			return result.FromError(err, fileName+" updated but attributes can't be set")
 		}
 		return result.NewUpdated(fileName + " updated")
 	}
-	return result.NewUnchanged(fileName + " already has some user defined content")
+	return withAttributes(filePath, fileName, attrs, result.NewUnchanged(fileName+" already has some user defined content"))
 }
 
-func replaceStringFileContent(filePath, newContent string) error {
-	return writeStringInFile(filePath, newContent, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+// SameContent checks if two files have the same content.
+// The files are compared chunk by chunk, without loading them into memory.
+func SameContent(file1, file2 string) (bool, error) {
+	file1 = strings.Replace(file1, "~", HomeDir(), 1)
+	file2 = strings.Replace(file2, "~", HomeDir(), 1)
+
+	info1, err := os.Stat(file1)
+	if err != nil {
+		return false, err
+	}
+	info2, err := os.Stat(file2)
+	if err != nil {
+		return false, err
+	}
+	if info1.Size() != info2.Size() {
+		return false, nil
+	}
+
+	f1, err := os.Open(file1)
+	if err != nil {
+		return false, err
+	}
+	defer f1.Close()
+	f2, err := os.Open(file2)
+	if err != nil {
+		return false, err
+	}
+	defer f2.Close()
+
+	buf1 := make([]byte, 64*1024)
+	buf2 := make([]byte, 64*1024)
+	for {
+		n1, err1 := io.ReadFull(f1, buf1)
+		n2, err2 := io.ReadFull(f2, buf2)
+		if n1 != n2 || !bytes.Equal(buf1[:n1], buf2[:n2]) {
+			return false, nil
+		}
+		if err1 == io.EOF || err1 == io.ErrUnexpectedEOF {
+			return err2 == err1, nil
+		}
+		if err1 != nil {
+			return false, err1
+		}
+		if err2 != nil {
+			return false, err2
+		}
+	}
 }
 
-func replaceBinaryFileContent(filePath string, newContent []byte) error {
-	return writeBinaryInFile(filePath, newContent, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+// withAttributes applies the optional attributes of a file with an unchanged content.
+// Returns the unchanged result if the file was already compliant.
+func withAttributes(filePath, fileName string, attrs []FileAttributes, unchanged result.Result) result.Result {
+	changes, err := applyAttributes(filePath, attrs)
+	if err != nil {
+		return result.FromError(err, "Can't set attributes of "+fileName)
+	}
+	if changes != "" {
+		return result.NewUpdated(fileName + " " + changes)
+	}
+	return unchanged
 }
 
-func writeStringInFile(filePath, fileText string, flag int) error {
-	return writeBinaryInFile(filePath, []byte(fileText), flag)
+func replaceStringFileContent(filePath, newContent string, attrs ...FileAttributes) error {
+	return writeStringInFile(filePath, newContent, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, attrs...)
 }
 
-func writeBinaryInFile(filePath string, content []byte, flag int) error {
+func replaceBinaryFileContent(filePath string, newContent []byte, attrs ...FileAttributes) error {
+	return writeBinaryInFile(filePath, newContent, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, attrs...)
+}
+
+func writeStringInFile(filePath, fileText string, flag int, attrs ...FileAttributes) error {
+	return writeBinaryInFile(filePath, []byte(fileText), flag, attrs...)
+}
+
+// writeBinaryInFile writes a file in place, created with the mode of the attributes (0644 by default).
+// The attributes are applied to the open file before its content is written.
+func writeBinaryInFile(filePath string, content []byte, flag int, attrs ...FileAttributes) error {
 	filePath = strings.Replace(filePath, "~", HomeDir(), 1)
 
 	if err := os.MkdirAll(path.Dir(filePath), 0775); err != nil {
 		return err
 	}
 
-	f, err := os.OpenFile(filePath, flag, 0644)
+	// The file is truncated once the attributes are set,
+	// so that the new content is never readable with the previous mode or owner
+	f, err := os.OpenFile(filePath, flag&^os.O_TRUNC, fileMode(attrs, 0644)&os.ModePerm)
 	if err != nil {
 		return err
 	}
 
+	if err := setOpenFileAttributes(f, attrs); err != nil {
+		f.Close()
+		return err
+	}
+	if flag&os.O_TRUNC != 0 {
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return err
+		}
+	}
 	if _, err := f.Write(content); err != nil {
 		f.Close()
 		return err
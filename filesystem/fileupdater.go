@@ -57,12 +57,12 @@ func CopyFileWithUpdate(srcFile, dstFile, startwith, replacement string, appendI
 
 	originalSrcContentStr, err1 := ReadFileAsString(srcFile)
 	if err1 != nil {
-		return result.NewError(err1.Error())
+		return result.FromError(err1, "")
 	}
 
 	originalDstContentStr, err2 := ReadFileAsStringOrEmptyIfNotExists(dstFile)
 	if err2 != nil {
-		return result.NewError(err2.Error())
+		return result.FromError(err2, "")
 	}
 
 	finalContent := updateLine(originalSrcContentStr, startwith, replacement, appendIfNoMatch)
@@ -90,7 +90,7 @@ func UpdateLineInFile(filePath, startwith, replacement string, appendIfNoMatch b
 
 	originalContent, err1 := ReadFileAsString(filePath)
 	if err1 != nil {
-		return result.NewError(err1.Error())
+		return result.FromError(err1, "")
 	}
 
 	finalContent := updateLine(originalContent, startwith, replacement, appendIfNoMatch)
@@ -114,7 +114,7 @@ func RemoveLineInFile(filePath, startwith string, fullline bool) result.Result {
 
 	originalContent, err1 := ReadFileAsString(filePath)
 	if err1 != nil {
-		return result.NewError(err1.Error())
+		return result.FromError(err1, "")
 	}
 
 	finalContent := removeLine(originalContent, startwith, fullline)
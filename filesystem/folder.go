@@ -100,19 +100,29 @@ func IsEmptyFolder(folderPath string) (string, error) {
 }
 
 // CreateFolderIfNeeded creates a folder if it does NOT exists.
+// The folder mode is 0775, unless the optional attributes specify another one.
+// The optional attributes (mode, owner) are applied even if the folder already exists,
+// but NOT to the missing parent folders.
 // Returns a string which describes what has been done, or an error message.
-func CreateFolderIfNeeded(folderPath string) result.Result {
+// The result subject is the folder path.
+func CreateFolderIfNeeded(folderPath string, attrs ...FileAttributes) result.Result {
 	folderPath = strings.Replace(folderPath, "~", HomeDir(), 1)
+	return createFolderIfNeeded(folderPath, attrs).WithSubject(folderPath)
+}
 
+func createFolderIfNeeded(folderPath string, attrs []FileAttributes) result.Result {
 	if exists, err := FolderExists(folderPath); err != nil {
 		return result.NewError("Don't know if folder " + folderPath + " exists")
 	} else if exists {
-		return result.NewUnchanged("Folder " + folderPath + " already exists")
+		return withAttributes(folderPath, "Folder "+folderPath, attrs, result.NewUnchanged("Folder "+folderPath+" already exists"))
 	}
 
 	// create folder
 	if err := os.MkdirAll(folderPath, 0775); err != nil {
-		return result.NewError("Error while creating " + folderPath + ": " + err.Error())
+		return result.FromError(err, "Error while creating "+folderPath)
+	}
+	if _, err := applyAttributes(folderPath, attrs); err != nil {
+		return result.FromError(err, "Folder "+folderPath+" created but attributes can't be set")
 	}
 
 	return result.NewCreated("Folder " + folderPath + " created")
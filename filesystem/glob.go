@@ -0,0 +1,14 @@
+package filesystem
+
+import (
+	"github.com/gandrille/go-commons/internal/pathutil"
+)
+
+// MatchGlob checks if a slash separated path matches a glob pattern.
+// The syntax is the one of path.Match, plus "**" which matches zero or more folders
+// (ie "META-INF/**/*.xml" matches "META-INF/a.xml" and "META-INF/maven/b/pom.xml").
+// A trailing slash in the name (folders in archives) is ignored.
+// The only possible error is path.ErrBadPattern.
+func MatchGlob(pattern, name string) (bool, error) {
+	return pathutil.MatchGlob(pattern, name)
+}
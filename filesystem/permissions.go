@@ -0,0 +1,305 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// FileAttributes are the optional mode and owner of the files and folders created or written
+// by CreateFolderIfNeeded, WriteStringFile and WriteBinaryFile.
+type FileAttributes struct {
+	Mode  os.FileMode // permission bits (with setuid, setgid and sticky), the default mode is used if zero
+	Owner string      // user name or uid, unchanged if empty
+	Group string      // group name or gid, unchanged if empty
+}
+
+// EnsureMode sets the permission bits (with setuid, setgid and sticky) of a file or folder (ie 0600 for ~/.ssh/config).
+// Symlinks are followed.
+func EnsureMode(filePath string, mode os.FileMode) result.Result {
+	fileName := strings.Replace(filePath, HomeDir(), "~", 1)
+	filePath = strings.Replace(filePath, "~", HomeDir(), 1)
+
+	changed, err := ensureMode(filePath, mode)
+	if err != nil {
+		return result.FromError(err, "Can't update mode of "+fileName).WithSubject(filePath)
+	}
+	if changed != "" {
+		return result.NewUpdated(fileName + " " + changed).WithSubject(filePath).WithDiff(changed)
+	}
+	return result.NewUnchanged(fileName + " already has mode " + (mode & modeBits).String()).WithSubject(filePath)
+}
+
+// EnsureOwner sets the owner and the group of a file or folder.
+// The owner and the group are names or numeric ids, an empty string keeps the current one.
+// Symlinks are followed.
+func EnsureOwner(filePath, owner, group string) result.Result {
+	fileName := strings.Replace(filePath, HomeDir(), "~", 1)
+	filePath = strings.Replace(filePath, "~", HomeDir(), 1)
+
+	uid, gid, err := lookupOwner(owner, group)
+	if err != nil {
+		return result.FromError(err, "Can't update owner of "+fileName).WithSubject(filePath)
+	}
+
+	changed, err := ensureOwner(filePath, uid, gid)
+	if err != nil {
+		return result.FromError(err, "Can't update owner of "+fileName).WithSubject(filePath)
+	}
+	if changed != "" {
+		return result.NewUpdated(fileName + " " + changed).WithSubject(filePath).WithDiff(changed)
+	}
+	return result.NewUnchanged(fileName + " already has expected owner").WithSubject(filePath)
+}
+
+// EnsureModeRecursive sets the permission bits of all the files and folders of a subtree, including the root.
+// A zero mode leaves the files (or the folders) unchanged. Symlinks are NOT followed, nor updated.
+func EnsureModeRecursive(rootPath string, fileMode, dirMode os.FileMode) result.Set {
+	rootPath = strings.Replace(rootPath, "~", HomeDir(), 1)
+	results := result.NewSet(nil, "Modes of "+strings.Replace(rootPath, HomeDir(), "~", 1))
+
+	err := filepath.Walk(rootPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		mode := fileMode
+		if info.IsDir() {
+			mode = dirMode
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+		if mode != 0 {
+			results.Add(EnsureMode(filePath, mode))
+		}
+		return nil
+	})
+	if err != nil {
+		results.Add(result.FromError(err, "Can't read "+rootPath))
+	}
+	return results
+}
+
+// EnsureOwnerRecursive sets the owner and the group of all the files and folders of a subtree, including the root.
+// See EnsureOwner. Symlinks are NOT followed, nor updated.
+func EnsureOwnerRecursive(rootPath, owner, group string) result.Set {
+	rootPath = strings.Replace(rootPath, "~", HomeDir(), 1)
+	results := result.NewSet(nil, "Owners of "+strings.Replace(rootPath, HomeDir(), "~", 1))
+
+	uid, gid, err := lookupOwner(owner, group)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't update owners of "+rootPath))
+		return results
+	}
+
+	err = filepath.Walk(rootPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		fileName := strings.Replace(filePath, HomeDir(), "~", 1)
+		if changed, err := ensureOwner(filePath, uid, gid); err != nil {
+			results.Add(result.FromError(err, "Can't update owner of "+fileName).WithSubject(filePath))
+		} else if changed != "" {
+			results.Add(result.NewUpdated(fileName + " " + changed).WithSubject(filePath).WithDiff(changed))
+		} else {
+			results.Add(result.NewUnchanged(fileName + " already has expected owner").WithSubject(filePath))
+		}
+		return nil
+	})
+	if err != nil {
+		results.Add(result.FromError(err, "Can't read "+rootPath))
+	}
+	return results
+}
+
+// =============================================
+
+// applyAttributes applies the optional attributes of the create and write helpers.
+// Returns a description of the changes, or an empty string if the file was already compliant.
+func applyAttributes(filePath string, attrs []FileAttributes) (string, error) {
+	var changes []string
+	for _, attr := range attrs {
+		if attr.Mode != 0 {
+			changed, err := ensureMode(filePath, attr.Mode)
+			if err != nil {
+				return "", err
+			}
+			if changed != "" {
+				changes = append(changes, changed)
+			}
+		}
+
+		if attr.Owner != "" || attr.Group != "" {
+			uid, gid, err := lookupOwner(attr.Owner, attr.Group)
+			if err != nil {
+				return "", err
+			}
+			changed, err := ensureOwner(filePath, uid, gid)
+			if err != nil {
+				return "", err
+			}
+			if changed != "" {
+				changes = append(changes, changed)
+			}
+		}
+	}
+	return strings.Join(changes, ", "), nil
+}
+
+// setOpenFileAttributes applies the attributes to an open file.
+// The owner is set first, since changing it clears the setuid and setgid bits.
+func setOpenFileAttributes(f *os.File, attrs []FileAttributes) error {
+	for _, attr := range attrs {
+		if attr.Owner == "" && attr.Group == "" {
+			continue
+		}
+		uid, gid, err := lookupOwner(attr.Owner, attr.Group)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if uid == int(stat.Uid) {
+				uid = -1
+			}
+			if gid == int(stat.Gid) {
+				gid = -1
+			}
+		}
+		if uid != -1 || gid != -1 {
+			if err := f.Chown(uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+
+	if mode := fileMode(attrs, 0) & modeBits; mode != 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Mode()&modeBits != mode {
+			return f.Chmod(mode)
+		}
+	}
+	return nil
+}
+
+// modeBits are the mode bits set by chmod: permissions, setuid, setgid and sticky.
+const modeBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// fileMode gets the mode of the optional attributes, or the default mode.
+func fileMode(attrs []FileAttributes, defaultMode os.FileMode) os.FileMode {
+	for _, attr := range attrs {
+		if attr.Mode != 0 {
+			defaultMode = attr.Mode
+		}
+	}
+	return defaultMode
+}
+
+func ensureMode(filePath string, mode os.FileMode) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	mode &= modeBits
+	if info.Mode()&modeBits == mode {
+		return "", nil
+	}
+	if err := os.Chmod(filePath, mode); err != nil {
+		return "", err
+	}
+	return "mode updated from " + (info.Mode() & modeBits).String() + " to " + mode.String(), nil
+}
+
+// ensureOwner changes the owner and the group of a file, -1 keeps the current one.
+func ensureOwner(filePath string, uid, gid int) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", errors.New("Can't get owner of " + filePath)
+	}
+
+	var changes []string
+	if uid != -1 && uint32(uid) != stat.Uid {
+		changes = append(changes, "owner updated from "+userName(int(stat.Uid))+" to "+userName(uid))
+	} else {
+		uid = -1
+	}
+	if gid != -1 && uint32(gid) != stat.Gid {
+		changes = append(changes, "group updated from "+groupName(int(stat.Gid))+" to "+groupName(gid))
+	} else {
+		gid = -1
+	}
+
+	if len(changes) == 0 {
+		return "", nil
+	}
+	if err := os.Chown(filePath, uid, gid); err != nil {
+		return "", err
+	}
+	return strings.Join(changes, ", "), nil
+}
+
+// lookupOwner resolves the owner and the group, -1 is returned for empty strings.
+func lookupOwner(owner, group string) (int, int, error) {
+	uid, gid := -1, -1
+
+	if owner != "" {
+		if id, err := strconv.Atoi(owner); err == nil {
+			uid = id
+		} else if u, err := user.Lookup(owner); err != nil {
+			return -1, -1, err
+		} else if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, errors.New("Invalid uid for user " + owner + ": " + u.Uid)
+		}
+	}
+
+	if group != "" {
+		if id, err := strconv.Atoi(group); err == nil {
+			gid = id
+		} else if g, err := user.LookupGroup(group); err != nil {
+			return -1, -1, err
+		} else if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, errors.New("Invalid gid for group " + group + ": " + g.Gid)
+		}
+	}
+
+	return uid, gid, nil
+}
+
+func userName(uid int) string {
+	if u, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		return u.Username
+	}
+	return strconv.Itoa(uid)
+}
+
+func groupName(gid int) string {
+	if g, err := user.LookupGroupId(strconv.Itoa(gid)); err == nil {
+		return g.Name
+	}
+	return strconv.Itoa(gid)
+}
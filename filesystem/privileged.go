@@ -0,0 +1,87 @@
+package filesystem
+
+import (
+	"context"
+	"os/user"
+	"strings"
+
+	"github.com/gandrille/go-commons/misc"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// WriteStringFileAs is the same as WriteStringFile, but the file is written by another user ("root" for root).
+// ~ is the home directory of this user.
+func WriteStringFileAs(username, filePath, newContent string, overwrite bool) result.Result {
+	return WriteBinaryFileAs(username, filePath, []byte(newContent), overwrite)
+}
+
+// WriteBinaryFileAs is the same as WriteBinaryFile, but the file is written by another user ("root" for root).
+// ~ is the home directory of this user.
+// Escalation (see misc.Escalation) is skipped if the current process already runs as this user,
+// otherwise a single escalated command checks and writes the file.
+func WriteBinaryFileAs(username, filePath string, newContent []byte, writeIfFileExists bool) result.Result {
+	if misc.IsCurrentUser(username) {
+		return WriteBinaryFile(filePath, newContent, writeIfFileExists)
+	}
+
+	if strings.HasPrefix(filePath, "~") {
+		u, err := user.Lookup(username)
+		if err != nil {
+			return result.FromError(err, "Can't find home directory of "+username)
+		}
+		filePath = strings.Replace(filePath, "~", u.HomeDir, 1)
+	}
+	fileName := filePath + " (as " + username + ")"
+
+	// A single escalated command, so that pkexec asks for the password only once
+	write := "0"
+	if writeIfFileExists {
+		write = "1"
+	}
+	res := runAs(username, string(newContent), "/bin/sh", "-c", writeFileScript, "sh", filePath, write)
+	if !res.IsSuccess() {
+		return res.Result(fileName+" writing error", result.Error)
+	}
+
+	switch output := strings.TrimSpace(res.Stdout); output {
+	case "created":
+		return result.NewCreated(fileName + " created")
+	case "not-regular":
+		return result.NewError(fileName + " exists but is not a regular file")
+	case "unchanged":
+		return result.NewUnchanged(fileName + " already has expected content")
+	case "user-defined":
+		return result.NewUnchanged(fileName + " already has some user defined content")
+	case "updated":
+		return result.NewUpdated(fileName + " updated")
+	default:
+		return result.NewError(fileName + " writing error: unexpected output '" + output + "'")
+	}
+}
+
+// writeFileScript writes its standard input into the file $1, if it does NOT exist or if $2 is 1.
+// It prints what has been done.
+const writeFileScript = `set -e
+tmp=$(mktemp)
+trap 'rm -f "$tmp"' EXIT
+cat > "$tmp"
+status=created
+if [ -e "$1" ] || [ -L "$1" ]; then
+	if [ ! -f "$1" ]; then echo not-regular; exit 0; fi
+	if cmp -s "$tmp" "$1"; then echo unchanged; exit 0; fi
+	if [ "$2" != 1 ]; then echo user-defined; exit 0; fi
+	status=updated
+fi
+mkdir -p -- "$(dirname -- "$1")"
+cat "$tmp" > "$1"
+echo $status
+`
+
+func runAs(username, stdin, name string, args ...string) misc.CmdResult {
+	return misc.RunAs(context.Background(), username, misc.CmdOptions{Stdin: stdin}, name, args...)
+}
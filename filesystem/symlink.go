@@ -19,7 +19,7 @@ func UpdateOrCreateSymlink(existing, linkname string) result.Result {
 
 	// Check if existing (source) exists
 	if exists, err := Exists(existing); !exists && err != nil {
-		return result.NewError("Error while checking if " + existing + " exists: " + err.Error())
+		return result.FromError(err, "Error while checking if "+existing+" exists")
 	} else if !exists {
 		return result.NewError("Symbolic link destination " + existing + " does NOT exist")
 	}
@@ -41,14 +41,14 @@ func UpdateOrCreateSymlink(existing, linkname string) result.Result {
 
 			// Unlink
 			if err := os.Remove(linkname); err != nil {
-				return result.NewError("Error while removing symbolic link " + actual + ": " + err.Error())
+				return result.FromError(err, "Error while removing symbolic link "+actual)
 			}
 		}
 	}
 
 	// Create link
 	if err := os.Symlink(existing, linkname); err != nil {
-		return result.NewError("Error while creating symbolic link " + linkname + ": " + err.Error())
+		return result.FromError(err, "Error while creating symbolic link "+linkname)
 	}
 
 	return result.NewUpdated("symbolic link " + linkname + " is now pointing to " + existing)
@@ -0,0 +1,409 @@
+package filesystem
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/internal/pathutil"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// SymlinkPolicy tells how SyncTree handles the symlinks of the source tree.
+type SymlinkPolicy int
+
+const (
+	CopySymlinks   SymlinkPolicy = iota // symlinks are created with the same target
+	FollowSymlinks                      // the files and folders symlinks point to are copied
+	SkipSymlinks                        // symlinks are ignored
+)
+
+// SyncOptions configures SyncTree.
+type SyncOptions struct {
+	Mirror        bool          // files and folders of the destination which are NOT in the source are removed
+	Checksum      bool          // files are compared by content, instead of size and modification time
+	Include       []string      // if NOT empty, only the files matching one of these patterns are synchronized
+	Exclude       []string      // files and folders matching one of these patterns are ignored (and never removed)
+	Symlinks      SymlinkPolicy // how symlinks are handled
+	PreserveModes bool          // modes of existing files are updated (new files always get the source mode)
+	DryRun        bool          // nothing is written, the results tell what would be done
+}
+
+// SyncTree synchronizes a destination folder with a source folder, like rsync does.
+// Include and Exclude are glob patterns (see MatchGlob) matching the slash separated relative paths,
+// or only the base names for patterns without "/".
+// Modification times are copied, so that unchanged files are detected without reading them.
+func SyncTree(src, dst string, options SyncOptions) result.Set {
+	src = filepath.Clean(strings.Replace(src, "~", HomeDir(), 1))
+	dst = filepath.Clean(strings.Replace(dst, "~", HomeDir(), 1))
+
+	message := "Synchronization of " + strings.Replace(src, HomeDir(), "~", 1) + " into " + strings.Replace(dst, HomeDir(), "~", 1)
+	if options.DryRun {
+		message += " (dry run)"
+	}
+	results := result.NewSet(nil, message)
+
+	info, err := os.Stat(src)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't read "+src))
+		return results
+	}
+	if !info.IsDir() {
+		results.Add(result.NewError(src + " is not a folder"))
+		return results
+	}
+
+	// The source would be synchronized with itself, or copied into itself endlessly
+	realSrc, errSrc := pathutil.RealPath(src)
+	realDst, errDst := pathutil.RealPath(dst)
+	if rel, err := filepath.Rel(realSrc, realDst); errSrc == nil && errDst == nil && err == nil {
+		if rel == "." {
+			results.Add(result.NewError(src + " can't be synchronized with itself"))
+			return results
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			results.Add(result.NewError(dst + " is inside " + src))
+			return results
+		}
+	}
+
+	s := syncer{options, &results, map[string]bool{}, map[string]os.FileMode{}}
+	s.syncFolder(src, dst, "")
+	s.applyFolderModes()
+	return results
+}
+
+// =============================================
+
+type syncer struct {
+	options    SyncOptions
+	results    *result.Set
+	visited    map[string]bool        // real paths of the source folders, to avoid loops when following symlinks
+	folderMode map[string]os.FileMode // modes of the destination folders, set once their content is synchronized
+}
+
+func (s *syncer) add(res result.Result) {
+	if s.options.DryRun {
+		res = res.WithMessage(res.Message() + " (dry run)")
+	}
+	s.results.Add(res)
+}
+
+func (s *syncer) syncFolder(srcDir, dstDir, rel string) {
+	if realDir, err := filepath.EvalSymlinks(srcDir); err == nil {
+		s.visited[realDir] = true
+		defer delete(s.visited, realDir)
+	}
+
+	entries, err := ioutil.ReadDir(srcDir)
+	if err != nil {
+		s.add(result.FromError(err, "Can't read "+srcDir))
+		return
+	}
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		name := entry.Name()
+		names[name] = true
+		s.syncEntry(filepath.Join(srcDir, name), filepath.Join(dstDir, name), path.Join(rel, name), entry)
+	}
+
+	if s.options.Mirror {
+		s.removeExtraneous(dstDir, rel, names)
+	}
+}
+
+func (s *syncer) syncEntry(srcPath, dstPath, rel string, info os.FileInfo) {
+	if pathutil.MatchPatterns(s.options.Exclude, rel) {
+		return
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch s.options.Symlinks {
+		case SkipSymlinks:
+			return
+		case CopySymlinks:
+			if s.isIncluded(rel) {
+				s.add(s.syncSymlink(srcPath, dstPath).WithSubject(dstPath))
+			}
+			return
+		}
+
+		target, err := os.Stat(srcPath)
+		if err != nil {
+			s.add(result.NewSkipped(srcPath + " is NOT synchronized: broken symlink"))
+			return
+		}
+		info = target
+	}
+
+	switch {
+	case info.IsDir():
+		if realDir, err := filepath.EvalSymlinks(srcPath); err == nil && s.visited[realDir] {
+			s.add(result.NewSkipped(srcPath + " is NOT synchronized: symlink loop"))
+			return
+		}
+		if len(s.options.Include) == 0 {
+			if res := s.syncFolderEntry(dstPath, info); !res.IsUnchanged() {
+				s.add(res.WithSubject(dstPath))
+			}
+		}
+		s.syncFolder(srcPath, dstPath, rel)
+	case info.Mode().IsRegular():
+		if s.isIncluded(rel) {
+			s.add(s.syncFile(srcPath, dstPath, info).WithSubject(dstPath))
+		}
+	default:
+		if s.isIncluded(rel) {
+			s.add(result.NewSkipped(srcPath + " is NOT synchronized: unsupported file type"))
+		}
+	}
+}
+
+// syncFolderEntry creates a folder.
+// The folder stays writable by its owner until its content is synchronized (see applyFolderModes).
+func (s *syncer) syncFolderEntry(dstPath string, info os.FileInfo) result.Result {
+	fileName := strings.Replace(dstPath, HomeDir(), "~", 1)
+
+	cur, err := os.Lstat(dstPath)
+	if err == nil && cur.IsDir() {
+		mode := cur.Mode().Perm()
+		if s.options.PreserveModes {
+			mode = info.Mode().Perm()
+		}
+		if !s.options.DryRun {
+			if err := s.deferFolderMode(dstPath, cur.Mode().Perm(), mode); err != nil {
+				return result.FromError(err, "Can't update mode of "+fileName)
+			}
+		}
+		if mode != cur.Mode().Perm() {
+			return result.NewUpdated(fileName + " mode updated to " + mode.String())
+		}
+		return result.NewUnchanged(fileName + " folder already exists")
+	}
+
+	exists := err == nil
+	if !s.options.DryRun {
+		if exists {
+			if err := os.Remove(dstPath); err != nil {
+				return result.FromError(err, "Can't replace "+fileName+" by a folder")
+			}
+		}
+		if err := os.MkdirAll(dstPath, 0755); err != nil {
+			return result.FromError(err, fileName+" creation error")
+		}
+		created, err := os.Stat(dstPath)
+		if err != nil {
+			return result.FromError(err, fileName+" creation error")
+		}
+		if err := s.deferFolderMode(dstPath, created.Mode().Perm(), info.Mode().Perm()); err != nil {
+			return result.FromError(err, fileName+" creation error")
+		}
+	}
+
+	if exists {
+		return result.NewUpdated(fileName + " replaced by a folder")
+	}
+	return result.NewCreated(fileName + " folder created")
+}
+
+// deferFolderMode makes a folder writable by its owner, and records the mode to set once its content is synchronized.
+func (s *syncer) deferFolderMode(dstPath string, cur, mode os.FileMode) error {
+	if cur&0700 != 0700 {
+		if err := os.Chmod(dstPath, cur|0700); err != nil {
+			return err
+		}
+		cur |= 0700
+	}
+	if cur != mode {
+		s.folderMode[dstPath] = mode
+	}
+	return nil
+}
+
+// applyFolderModes sets the modes of the destination folders,
+// subfolders before their parent, so that read only folders can be synchronized.
+func (s *syncer) applyFolderModes() {
+	var folders []string
+	for folder := range s.folderMode {
+		folders = append(folders, folder)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(folders)))
+
+	for _, folder := range folders {
+		if err := os.Chmod(folder, s.folderMode[folder]); err != nil {
+			fileName := strings.Replace(folder, HomeDir(), "~", 1)
+			s.add(result.FromError(err, "Can't update mode of "+fileName).WithSubject(folder))
+		}
+	}
+}
+
+func (s *syncer) syncSymlink(srcPath, dstPath string) result.Result {
+	fileName := strings.Replace(dstPath, HomeDir(), "~", 1)
+
+	target, err := os.Readlink(srcPath)
+	if err != nil {
+		return result.FromError(err, "Can't read "+srcPath)
+	}
+
+	cur, err := os.Lstat(dstPath)
+	exists := err == nil
+	if exists {
+		if cur.IsDir() {
+			return result.NewError(fileName + " exists but is a folder")
+		}
+		if curTarget, err := os.Readlink(dstPath); err == nil && curTarget == target {
+			return result.NewUnchanged(fileName + " already points to " + target)
+		}
+	}
+
+	if !s.options.DryRun {
+		if exists {
+			if err := os.Remove(dstPath); err != nil {
+				return result.FromError(err, "Can't update "+fileName)
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return result.FromError(err, fileName+" creation error")
+		}
+		if err := os.Symlink(target, dstPath); err != nil {
+			return result.FromError(err, fileName+" creation error")
+		}
+	}
+
+	if exists {
+		return result.NewUpdated(fileName + " updated, now pointing to " + target)
+	}
+	return result.NewCreated(fileName + " created, pointing to " + target)
+}
+
+func (s *syncer) syncFile(srcPath, dstPath string, info os.FileInfo) result.Result {
+	fileName := strings.Replace(dstPath, HomeDir(), "~", 1)
+
+	cur, err := os.Lstat(dstPath)
+	exists := err == nil
+	if exists && cur.IsDir() {
+		return result.NewError(fileName + " exists but is a folder")
+	}
+
+	if exists && cur.Mode().IsRegular() {
+		same, err := s.sameFile(srcPath, dstPath, info, cur)
+		if err != nil {
+			return result.FromError(err, fileName+" already exists but we can't read its content")
+		}
+		if same {
+			if !s.options.PreserveModes || cur.Mode().Perm() == info.Mode().Perm() {
+				return result.NewUnchanged(fileName + " already has expected content")
+			}
+			if !s.options.DryRun {
+				if err := os.Chmod(dstPath, info.Mode().Perm()); err != nil {
+					return result.FromError(err, "Can't update mode of "+fileName)
+				}
+			}
+			return result.NewUpdated(fileName + " mode updated to " + info.Mode().Perm().String())
+		}
+	}
+
+	mode := info.Mode().Perm()
+	if exists && cur.Mode().IsRegular() && !s.options.PreserveModes {
+		mode = cur.Mode().Perm()
+	}
+
+	if !s.options.DryRun {
+		if err := copyFile(srcPath, dstPath, mode, info.ModTime()); err != nil {
+			return result.FromError(err, fileName+" writing error")
+		}
+	}
+
+	if exists {
+		return result.NewUpdated(fileName + " updated")
+	}
+	return result.NewCreated(fileName + " created")
+}
+
+func (s *syncer) sameFile(srcPath, dstPath string, src, dst os.FileInfo) (bool, error) {
+	if src.Size() != dst.Size() {
+		return false, nil
+	}
+	if s.options.Checksum {
+		return SameContent(srcPath, dstPath)
+	}
+	return src.ModTime().Truncate(time.Second).Equal(dst.ModTime().Truncate(time.Second)), nil
+}
+
+// removeExtraneous removes the entries of a destination folder which are NOT in the source folder.
+// Excluded entries are kept, as well as the folders when only some files are included.
+func (s *syncer) removeExtraneous(dstDir, rel string, names map[string]bool) {
+	entries, err := ioutil.ReadDir(dstDir)
+	if err != nil {
+		return
+	}
+
+	var extraneous []os.FileInfo
+	for _, entry := range entries {
+		entryRel := path.Join(rel, entry.Name())
+		if names[entry.Name()] || pathutil.MatchPatterns(s.options.Exclude, entryRel) {
+			continue
+		}
+		if entry.IsDir() && len(s.options.Include) != 0 {
+			continue
+		}
+		if !entry.IsDir() && !s.isIncluded(entryRel) {
+			continue
+		}
+		extraneous = append(extraneous, entry)
+	}
+	sort.Slice(extraneous, func(i, j int) bool { return extraneous[i].Name() < extraneous[j].Name() })
+
+	for _, entry := range extraneous {
+		dstPath := filepath.Join(dstDir, entry.Name())
+		fileName := strings.Replace(dstPath, HomeDir(), "~", 1)
+		if !s.options.DryRun {
+			if err := os.RemoveAll(dstPath); err != nil {
+				s.add(result.FromError(err, "Can't remove "+fileName).WithSubject(dstPath))
+				continue
+			}
+		}
+		if entry.IsDir() {
+			s.add(result.NewRemoved(fileName + " folder removed").WithSubject(dstPath))
+		} else {
+			s.add(result.NewRemoved(fileName + " removed").WithSubject(dstPath))
+		}
+	}
+}
+
+func (s *syncer) isIncluded(rel string) bool {
+	return len(s.options.Include) == 0 || pathutil.MatchPatterns(s.options.Include, rel)
+}
+
+// =============================================
+
+// copyFile copies a file next to the destination, then moves it.
+func copyFile(srcPath, dstPath string, mode os.FileMode, modTime time.Time) error {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := CreateAtomicFile(dstPath)
+	if err != nil {
+		return err
+	}
+	defer tmp.Abort()
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+	return tmp.Commit(mode, modTime)
+}
@@ -0,0 +1,515 @@
+package fstab
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// Static information about the filesystems
+// See fstab(5) for the meaning of each field.
+const fstabFile = "/etc/fstab"
+
+// Entry is a line of an fstab file describing a filesystem.
+type Entry struct {
+	Spec    string // block device, UUID=xxx, LABEL=xxx, server:/path,...
+	File    string // mount point, or none for swap
+	VfsType string
+	Options []string
+	Freq    int
+	PassNo  int
+}
+
+// Fstab is the content of an fstab file.
+// Comments, blank lines, lines which can't be parsed and unchanged entries are kept as is.
+type Fstab struct {
+	lines []fstabLine
+}
+
+type fstabLine struct {
+	raw   string
+	entry *Entry
+}
+
+// Path gets the path of the fstab file inside root.
+// Use an empty root for the running system, or the mount point of an offline image.
+func Path(root string) string {
+	if root == "" {
+		return fstabFile
+	}
+	return filepath.Join(root, fstabFile)
+}
+
+// Read reads the fstab file inside root.
+// If the file does NOT exist, an empty Fstab is returned.
+func Read(root string) (Fstab, error) {
+	file := Path(root)
+	content, err := filesystem.ReadFileAsStringOrEmptyIfNotExists(file)
+	if err != nil {
+		return Fstab{}, err
+	}
+	return Parse(content), nil
+}
+
+// Parse parses the content of an fstab file.
+// Lines which can't be parsed are kept as is, like comments, so that the file can still be updated:
+// use Invalid for reporting them.
+func Parse(content string) Fstab {
+	var fstab Fstab
+	for _, raw := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			fstab.lines = append(fstab.lines, fstabLine{raw, nil})
+			continue
+		}
+
+		entry, err := parseEntry(trimmed)
+		if err != nil {
+			fstab.lines = append(fstab.lines, fstabLine{raw, nil})
+			continue
+		}
+		fstab.lines = append(fstab.lines, fstabLine{raw, &entry})
+	}
+	return fstab
+}
+
+// String gets the content of the fstab file.
+func (fstab Fstab) String() string {
+	var lines []string
+	for _, line := range fstab.lines {
+		lines = append(lines, line.raw)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Entries gets all the entries, in file order.
+func (fstab Fstab) Entries() []Entry {
+	var entries []Entry
+	for _, line := range fstab.lines {
+		if line.entry != nil {
+			entries = append(entries, *line.entry)
+		}
+	}
+	return entries
+}
+
+// Invalid describes the lines which are neither comments nor valid entries, in file order.
+func (fstab Fstab) Invalid() []string {
+	var invalid []string
+	for i, line := range fstab.lines {
+		trimmed := strings.TrimSpace(line.raw)
+		if line.entry != nil || trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if _, err := parseEntry(trimmed); err != nil {
+			invalid = append(invalid, "Line "+strconv.Itoa(i+1)+": "+err.Error())
+		}
+	}
+	return invalid
+}
+
+// Find gets the entry matching a key.
+// The key is either a mount point or a spec (ie UUID=xxx or /dev/sda1).
+func (fstab Fstab) Find(key string) (Entry, bool) {
+	if idx := fstab.find(key); idx != -1 {
+		return *fstab.lines[idx].entry, true
+	}
+	return Entry{}, false
+}
+
+// Set adds an entry, or replaces the one with the same mount point
+// (or the same spec for swap entries and UUID=xxx specs).
+// The new line is aligned with the existing ones.
+// Returns true if the content has been modified.
+func (fstab *Fstab) Set(entry Entry) bool {
+	idx := fstab.findEntry(entry)
+
+	if idx != -1 && fstab.lines[idx].entry.Equals(entry) {
+		return false
+	}
+
+	line := fstabLine{fstab.format(entry), &entry}
+	if idx != -1 {
+		fstab.lines[idx] = line
+	} else if n := len(fstab.lines); n > 0 && fstab.lines[n-1].raw == "" {
+		// keep the trailing newline at the end of the file
+		fstab.lines = append(fstab.lines[:n-1], line, fstabLine{"", nil})
+	} else {
+		fstab.lines = append(fstab.lines, line)
+	}
+	return true
+}
+
+// Remove removes the entry matching a key (see Find).
+// Returns true if an entry has been removed.
+func (fstab *Fstab) Remove(key string) bool {
+	idx := fstab.find(key)
+	if idx == -1 {
+		return false
+	}
+	fstab.lines = append(fstab.lines[:idx], fstab.lines[idx+1:]...)
+	return true
+}
+
+// EnsureEntry makes sure the fstab file inside root contains an entry.
+// An existing entry with the same mount point (or UUID) is replaced.
+// The file is created if it does NOT exist.
+func EnsureEntry(root string, entry Entry) result.Result {
+	file := Path(root)
+
+	if err := entry.Validate(); err != nil {
+		return result.FromError(err, "Invalid entry for "+entry.File)
+	}
+
+	fstab, err := Read(root)
+	if err != nil {
+		return result.FromError(err, "")
+	}
+
+	previous, found := Entry{}, false
+	if idx := fstab.findEntry(entry); idx != -1 {
+		previous, found = *fstab.lines[idx].entry, true
+	}
+
+	if !fstab.Set(entry) {
+		return result.NewUnchanged(file + " already has entry " + entry.String())
+	}
+
+	if err := write(file, fstab.String()); err != nil {
+		return result.FromError(err, file+" writing error")
+	}
+
+	if found {
+		return result.NewUpdated(file + " entry updated from " + previous.String() + " to " + entry.String())
+	}
+	return result.NewCreated(file + " entry " + entry.String() + " added")
+}
+
+// RemoveEntry removes the entry matching a key (mount point or spec) from the fstab file inside root.
+func RemoveEntry(root, key string) result.Result {
+	file := Path(root)
+
+	fstab, err := Read(root)
+	if err != nil {
+		return result.FromError(err, "")
+	}
+
+	if !fstab.Remove(key) {
+		return result.NewUnchanged(file + " has no entry for " + key)
+	}
+
+	if err := write(file, fstab.String()); err != nil {
+		return result.FromError(err, file+" writing error")
+	}
+	return result.NewRemoved(file + " entry for " + key + " removed")
+}
+
+// =============================================
+
+// String gets the entry as an fstab line, with a single space between fields.
+func (entry Entry) String() string {
+	return strings.Join(entry.fields(), " ")
+}
+
+// Equals checks if two entries have the same fields.
+func (entry Entry) Equals(other Entry) bool {
+	return entry.String() == other.String()
+}
+
+// HasOption checks if a mount option is set.
+func (entry Entry) HasOption(option string) bool {
+	for _, opt := range entry.Options {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the entry fields.
+func (entry Entry) Validate() error {
+	if entry.Spec == "" {
+		return errors.New("spec is empty")
+	}
+	if entry.File == "" {
+		return errors.New("mount point is empty")
+	}
+	if entry.File != "none" && entry.File != "swap" && !filepath.IsAbs(entry.File) {
+		return errors.New("mount point " + entry.File + " is not an absolute path")
+	}
+	if entry.VfsType == "" {
+		return errors.New("filesystem type is empty")
+	}
+	if entry.Freq < 0 {
+		return errors.New("dump frequency can't be negative")
+	}
+	if entry.PassNo < 0 || entry.PassNo > 2 {
+		return errors.New("pass number must be 0, 1 or 2")
+	}
+	return ValidateOptions(entry.Options)
+}
+
+// ValidateOptions checks mount options syntax,
+// and reports duplicated or contradictory options (ie ro and rw).
+// Filesystem specific options are NOT checked.
+func ValidateOptions(options []string) error {
+	if len(options) == 0 {
+		return errors.New("no mount option, use defaults")
+	}
+
+	seen := map[string]bool{}
+	for _, option := range options {
+		if option == "" {
+			return errors.New("empty mount option")
+		}
+		if strings.ContainsAny(option, ", \t\n") {
+			return errors.New("mount option '" + option + "' contains a separator")
+		}
+		name := option
+		if idx := strings.Index(option, "="); idx != -1 {
+			name = option[:idx]
+		}
+		if seen[name] {
+			return errors.New("mount option " + name + " is duplicated")
+		}
+		seen[name] = true
+	}
+
+	for _, pair := range contradictoryOptions {
+		if seen[pair[0]] && seen[pair[1]] {
+			return errors.New("mount options " + pair[0] + " and " + pair[1] + " are contradictory")
+		}
+	}
+	return nil
+}
+
+var contradictoryOptions = [][2]string{
+	{"ro", "rw"},
+	{"auto", "noauto"},
+	{"exec", "noexec"},
+	{"suid", "nosuid"},
+	{"dev", "nodev"},
+	{"sync", "async"},
+	{"atime", "noatime"},
+	{"user", "nouser"},
+	{"relatime", "norelatime"},
+	{"strictatime", "nostrictatime"},
+}
+
+// =============================================
+
+func (entry Entry) fields() []string {
+	return []string{
+		escape(entry.Spec),
+		escape(entry.File),
+		escape(entry.VfsType),
+		escape(strings.Join(entry.Options, ",")),
+		strconv.Itoa(entry.Freq),
+		strconv.Itoa(entry.PassNo),
+	}
+}
+
+// write replaces the fstab file, keeping its mode and owner, or creates it.
+// The content is written into a temporary file, then moved over the original one,
+// so that the file is never left partially written.
+func write(file, content string) error {
+	mode := os.FileMode(0644)
+	info, err := os.Stat(file)
+	if err == nil {
+		mode = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := filesystem.CreateAtomicFile(file)
+	if err != nil {
+		return err
+	}
+	defer tmp.Abort()
+
+	if _, err := tmp.WriteString(content); err != nil {
+		return err
+	}
+	if info != nil {
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if err := tmp.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+				return err
+			}
+		}
+	}
+	return tmp.Commit(mode, time.Time{})
+}
+
+func parseEntry(line string) (Entry, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || len(fields) > 6 {
+		return Entry{}, errors.New("expected 3 to 6 fields, got " + strconv.Itoa(len(fields)))
+	}
+
+	entry := Entry{
+		Spec:    unescape(fields[0]),
+		File:    unescape(fields[1]),
+		VfsType: unescape(fields[2]),
+		Options: []string{"defaults"},
+	}
+
+	if len(fields) > 3 {
+		entry.Options = strings.Split(unescape(fields[3]), ",")
+	}
+	if len(fields) > 4 {
+		freq, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return Entry{}, errors.New("invalid dump frequency " + fields[4])
+		}
+		entry.Freq = freq
+	}
+	if len(fields) > 5 {
+		passNo, err := strconv.Atoi(fields[5])
+		if err != nil {
+			return Entry{}, errors.New("invalid pass number " + fields[5])
+		}
+		entry.PassNo = passNo
+	}
+
+	return entry, nil
+}
+
+// find returns the index of the line matching a key, or -1.
+// Mount points are checked before specs.
+func (fstab Fstab) find(key string) int {
+	for i, line := range fstab.lines {
+		if line.entry != nil && line.entry.File == key {
+			return i
+		}
+	}
+	for i, line := range fstab.lines {
+		if line.entry != nil && sameSpec(line.entry.Spec, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// findEntry returns the index of the line an entry should replace, or -1.
+func (fstab Fstab) findEntry(entry Entry) int {
+	for i, line := range fstab.lines {
+		if line.entry == nil {
+			continue
+		}
+		if entry.File != "none" && entry.File != "swap" && line.entry.File == entry.File {
+			return i
+		}
+	}
+	for i, line := range fstab.lines {
+		if line.entry == nil {
+			continue
+		}
+		if (entry.File == "none" || entry.File == "swap" || strings.HasPrefix(entry.Spec, "UUID=")) && sameSpec(line.entry.Spec, entry.Spec) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameSpec compares specs, UUIDs being case insensitive.
+func sameSpec(spec1, spec2 string) bool {
+	if strings.HasPrefix(spec1, "UUID=") && strings.HasPrefix(spec2, "UUID=") {
+		return strings.EqualFold(spec1, spec2)
+	}
+	return spec1 == spec2
+}
+
+// format builds a line aligned on the columns of the existing entries.
+// If the existing entries use tabs, tabs are used.
+func (fstab Fstab) format(entry Entry) string {
+	fields := entry.fields()
+
+	var raws []string
+	for _, line := range fstab.lines {
+		if line.entry != nil {
+			raws = append(raws, strings.TrimRight(line.raw, " \t"))
+		}
+	}
+
+	// No reference line: single space separator
+	if len(raws) == 0 {
+		return strings.Join(fields, " ")
+	}
+
+	for _, raw := range raws {
+		if strings.Contains(raw, "\t") {
+			return strings.Join(fields, "\t")
+		}
+	}
+
+	// Each field starts on the most common column of the existing entries,
+	// or one space after the previous field if it is too long
+	counts := make([]map[int]int, len(fields))
+	for i := range counts {
+		counts[i] = map[int]int{}
+	}
+	for _, raw := range raws {
+		for i, start := range fieldStarts(raw) {
+			if i < len(counts) {
+				counts[i][start]++
+			}
+		}
+	}
+
+	var sb strings.Builder
+	for i, field := range fields {
+		if i != 0 {
+			padding := mostCommon(counts[i]) - sb.Len()
+			if padding < 1 {
+				padding = 1
+			}
+			sb.WriteString(strings.Repeat(" ", padding))
+		}
+		sb.WriteString(field)
+	}
+	return sb.String()
+}
+
+func mostCommon(counts map[int]int) int {
+	best, bestCount := 0, 0
+	for value, count := range counts {
+		if count > bestCount || (count == bestCount && value < best) {
+			best, bestCount = value, count
+		}
+	}
+	return best
+}
+
+func fieldStarts(line string) []int {
+	var starts []int
+	inField := false
+	for i, c := range line {
+		isSpace := c == ' ' || c == '\t'
+		if !isSpace && !inField {
+			starts = append(starts, i)
+		}
+		inField = !isSpace
+	}
+	return starts
+}
+
+// escape encodes spaces and tabs using octal sequences, as required by fstab(5).
+func escape(field string) string {
+	return strings.NewReplacer("\\", "\\134", " ", "\\040", "\t", "\\011").Replace(field)
+}
+
+func unescape(field string) string {
+	return strings.NewReplacer("\\134", "\\", "\\040", " ", "\\011", "\t").Replace(field)
+}
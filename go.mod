@@ -1,5 +1,15 @@
 module github.com/gandrille/go-commons
 
-go 1.13
+go 1.17
 
-require github.com/fatih/color v1.13.0
+require (
+	github.com/fatih/color v1.13.0
+	github.com/klauspost/compress v1.15.15
+	github.com/ulikunitz/xz v0.5.17
+)
+
+require (
+	github.com/mattn/go-colorable v0.1.9 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+)
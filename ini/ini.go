@@ -37,6 +37,37 @@ func GetValue(file, section, key string, failIfFileNotExists bool, failIfKeyNotE
 	return notFound(file, section, key, failIfKeyNotExists)
 }
 
+// GetSection gets all the key/value pairs of a section from an ini file.
+// If the file or the section does NOT exist, an empty map is returned.
+func GetSection(file, section string) (map[string]string, error) {
+	values := map[string]string{}
+
+	// Get file content
+	content, err := filesystem.ReadFileAsStringOrEmptyIfNotExists(file)
+	if err != nil {
+		return nil, errors.New("Error while reading file " + file + " content: " + err.Error())
+	}
+
+	// Find section
+	lines := strings.Split(content, "\n")
+	idx := getLineNumberOfSection(lines, section)
+	if idx == -1 {
+		return values, nil
+	}
+
+	// Read keys until next section
+	for i := idx; i < len(lines); i++ {
+		if isSec, _ := isSectionLine(lines[i]); isSec {
+			break
+		}
+		if isVal, curkey, curval := isKeyValLine(lines[i]); isVal {
+			values[curkey] = curval
+		}
+	}
+
+	return values, nil
+}
+
 // SetValue sets a value in an ini file.
 // with enclosewithspaces == true, the line is written with space around the '=' sign
 // returns true if the file has been created or modified
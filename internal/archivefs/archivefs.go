@@ -0,0 +1,277 @@
+package archivefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Read only io/fs.FS view of the entries of an archive, shared by the archive formats.
+// Folders which are NOT stored in the archive are synthesized from the names of the files.
+
+// Entry is a file (or a folder, or a symlink) inside an archive.
+type Entry interface {
+	Name() string
+	Size() uint64
+	Mode() fs.FileMode
+	ModTime() time.Time
+	IsDir() bool
+	Open() (io.ReadCloser, error)
+}
+
+// FS implements fs.FS, fs.ReadDirFS, fs.GlobFS and fs.StatFS.
+type FS struct {
+	nodes map[string]*node
+	paths []string // sorted
+}
+
+// node is a file or a folder of the FS.
+type node struct {
+	path     string
+	entry    Entry // nil for synthesized folders
+	isDir    bool
+	children []string // sorted names of the children
+}
+
+// New constructs an FS object.
+// Entries with invalid names (see fs.ValidPath) are ignored, as well as duplicated ones.
+func New(entries []Entry) *FS {
+	fsys := &FS{map[string]*node{".": {".", nil, true, nil}}, nil}
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), "/")
+		if name == "" || !fs.ValidPath(name) {
+			continue
+		}
+		if cur, found := fsys.nodes[name]; found {
+			// A synthesized folder is replaced by the stored one, for its metadata
+			if cur.entry == nil && entry.IsDir() {
+				cur.entry = entry
+			}
+			continue
+		}
+		if !fsys.addParents(name) {
+			continue
+		}
+		fsys.nodes[name] = &node{name, entry, entry.IsDir(), nil}
+		fsys.addChild(name)
+	}
+
+	for p, n := range fsys.nodes {
+		sort.Strings(n.children)
+		fsys.paths = append(fsys.paths, p)
+	}
+	sort.Strings(fsys.paths)
+	return fsys
+}
+
+// addParents synthesizes the missing parent folders of a path.
+// Returns false if a parent is a file.
+func (fsys *FS) addParents(name string) bool {
+	dir := path.Dir(name)
+	if cur, found := fsys.nodes[dir]; found {
+		return cur.isDir
+	}
+	if !fsys.addParents(dir) {
+		return false
+	}
+	fsys.nodes[dir] = &node{dir, nil, true, nil}
+	fsys.addChild(dir)
+	return true
+}
+
+func (fsys *FS) addChild(name string) {
+	parent := fsys.nodes[path.Dir(name)]
+	parent.children = append(parent.children, path.Base(name))
+}
+
+// Open opens a file or a folder.
+// The content of a file is only read on the first call to Read.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	n, err := fsys.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.isDir {
+		return &dir{fsys, n, 0}, nil
+	}
+	return &file{n, nil}, nil
+}
+
+// ReadDir reads a folder, the entries being sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := fsys.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return fsys.entriesOf(n, n.children), nil
+}
+
+// Glob gets the sorted names of the files and folders matching a pattern (see path.Match).
+func (fsys *FS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, p := range fsys.paths {
+		if matched, _ := path.Match(pattern, p); matched && p != "." {
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
+
+// Stat gets the information about a file or a folder.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	n, err := fsys.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return info{n}, nil
+}
+
+func (fsys *FS) lookup(op, name string) (*node, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	n, found := fsys.nodes[name]
+	if !found {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+// entriesOf gets the entries of some children of a folder.
+func (fsys *FS) entriesOf(parent *node, children []string) []fs.DirEntry {
+	list := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		list = append(list, info{fsys.nodes[path.Join(parent.path, child)]})
+	}
+	return list
+}
+
+// =============================================
+
+var errNotDir = errors.New("not a directory")
+var errIsDir = errors.New("is a directory")
+
+// info implements fs.FileInfo and fs.DirEntry.
+type info struct {
+	n *node
+}
+
+func (i info) Name() string {
+	return path.Base(i.n.path)
+}
+
+func (i info) Size() int64 {
+	if i.n.entry == nil || i.n.isDir {
+		return 0
+	}
+	return int64(i.n.entry.Size())
+}
+
+func (i info) Mode() fs.FileMode {
+	if i.n.entry == nil {
+		return fs.ModeDir | 0755
+	}
+	mode := i.n.entry.Mode()
+	if i.n.isDir {
+		mode |= fs.ModeDir
+	}
+	return mode
+}
+
+func (i info) ModTime() time.Time {
+	if i.n.entry == nil {
+		return time.Time{}
+	}
+	return i.n.entry.ModTime()
+}
+
+func (i info) IsDir() bool {
+	return i.n.isDir
+}
+
+func (i info) Sys() interface{} {
+	if i.n.entry == nil {
+		return nil
+	}
+	return i.n.entry
+}
+
+func (i info) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+func (i info) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+// file implements fs.File.
+type file struct {
+	n  *node
+	rc io.ReadCloser
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	return info{f.n}, nil
+}
+
+func (f *file) Read(buf []byte) (int, error) {
+	if f.rc == nil {
+		rc, err := f.n.entry.Open()
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.n.path, Err: err}
+		}
+		f.rc = rc
+	}
+	return f.rc.Read(buf)
+}
+
+func (f *file) Close() error {
+	if f.rc == nil {
+		return nil
+	}
+	return f.rc.Close()
+}
+
+// dir implements fs.ReadDirFile.
+type dir struct {
+	fsys   *FS
+	n      *node
+	offset int
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) {
+	return info{d.n}, nil
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.n.path, Err: errIsDir}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	children := d.n.children[d.offset:]
+	if count > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+		if count < len(children) {
+			children = children[:count]
+		}
+	}
+	d.offset += len(children)
+	return d.fsys.entriesOf(d.n, children), nil
+}
@@ -0,0 +1,305 @@
+package extract
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/internal/pathutil"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// Safe extraction of archive entries, shared by the archive formats.
+
+// Options configures the extraction of an archive.
+type Options struct {
+	StripComponents int  // number of leading folders removed from the names, entries without enough folders are ignored
+	KeepExisting    bool // existing files with another content are NOT overwritten
+}
+
+// Extractor writes archive entries into a destination folder.
+type Extractor struct {
+	dest       string
+	realDest   string // dest, with symlinks resolved
+	options    Options
+	folderMode map[string]os.FileMode
+}
+
+// New constructs an Extractor, creating the destination folder if needed.
+func New(dest string, options Options) (*Extractor, error) {
+	dest, err := filepath.Abs(strings.Replace(dest, "~", filesystem.HomeDir(), 1))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, err
+	}
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return nil, err
+	}
+	return &Extractor{dest, realDest, options, map[string]os.FileMode{}}, nil
+}
+
+// Extract writes an entry.
+// For symlinks, open gives the target of the link.
+// Returns false if the entry is ignored (see Options.StripComponents).
+func (x *Extractor) Extract(name string, mode os.FileMode, modTime time.Time, open func() (io.ReadCloser, error)) (result.Result, bool) {
+	if err := CheckName(name); err != nil {
+		return result.NewError(name + " is rejected: it would be extracted outside of " + x.dest), true
+	}
+
+	target, ok := x.Target(name)
+	if !ok {
+		return result.Result{}, false
+	}
+	fileName := strings.Replace(target, filesystem.HomeDir(), "~", 1)
+
+	if err := x.checkParents(target); err != nil {
+		return result.FromError(err, name+" is rejected"), true
+	}
+
+	switch {
+	case mode.IsDir():
+		return x.extractFolder(target, fileName, mode), true
+	case mode&os.ModeSymlink != 0:
+		return x.extractSymlink(target, fileName, open), true
+	case mode.IsRegular():
+		return x.extractFile(target, fileName, mode, modTime, open), true
+	default:
+		return result.NewSkipped(fileName + " is NOT extracted: unsupported file type"), true
+	}
+}
+
+// Target gets the path where an entry is extracted.
+// Returns false if the entry is ignored (see Options.StripComponents).
+// The name MUST have been checked with CheckName.
+func (x *Extractor) Target(name string) (string, bool) {
+	elements := strings.Split(strings.Trim(path.Clean(name), "/"), "/")
+	if len(elements) <= x.options.StripComponents || path.Clean(name) == "." {
+		return "", false
+	}
+	return filepath.Join(x.dest, filepath.FromSlash(strings.Join(elements[x.options.StripComponents:], "/"))), true
+}
+
+// checkParents checks that the folder of a target, once symlinks resolved, is inside the destination folder.
+func (x *Extractor) checkParents(target string) error {
+	realDir, err := pathutil.RealPath(filepath.Dir(target))
+	if err != nil {
+		return errors.New("it would be extracted through a symlink which can't be resolved: " + err.Error())
+	}
+	if !isInside(realDir, x.realDest) {
+		return errors.New("it would be extracted through a symlink pointing outside of " + x.dest)
+	}
+	return nil
+}
+
+// resolveLink resolves the target of a symlink created inside a folder, following the symlinks already extracted.
+// Every step of the resolution must be inside the destination folder.
+// ".." is only accepted after existing folders, since a symlink extracted later could change its meaning.
+func (x *Extractor) resolveLink(dir, linkTarget string) (string, error) {
+	if filepath.IsAbs(linkTarget) {
+		return "", errors.New("absolute target")
+	}
+
+	cur, err := pathutil.RealPath(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, element := range strings.Split(filepath.ToSlash(linkTarget), "/") {
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			if _, err := os.Lstat(cur); err != nil {
+				return "", errors.New(".. after a missing folder")
+			}
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, element)
+			if info, err := os.Lstat(cur); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if cur, err = filepath.EvalSymlinks(cur); err != nil {
+					return "", err
+				}
+			}
+		}
+		if !isInside(cur, x.realDest) {
+			return "", errors.New("target outside of " + x.dest)
+		}
+	}
+	return cur, nil
+}
+
+func (x *Extractor) extractFolder(target, fileName string, mode os.FileMode) result.Result {
+	x.folderMode[target] = mode.Perm()
+
+	info, err := os.Lstat(target)
+	if err == nil {
+		if info.IsDir() {
+			return result.NewUnchanged(fileName + " folder already exists")
+		}
+		return result.NewError(fileName + " exists but is not a folder")
+	}
+
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return result.FromError(err, fileName+" creation error")
+	}
+	return result.NewCreated(fileName + " folder created")
+}
+
+func (x *Extractor) extractSymlink(target, fileName string, open func() (io.ReadCloser, error)) result.Result {
+	rc, err := open()
+	if err != nil {
+		return result.FromError(err, fileName+" can't be read")
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+	rc.Close()
+	if err != nil {
+		return result.FromError(err, fileName+" can't be read")
+	}
+
+	linkTarget := string(content)
+	if _, err := x.resolveLink(filepath.Dir(target), linkTarget); err != nil {
+		return result.NewError(fileName + " is rejected: it is a symlink pointing outside of " + x.dest)
+	}
+
+	info, err := os.Lstat(target)
+	exists := err == nil
+	if exists {
+		if info.Mode()&os.ModeSymlink == 0 {
+			return result.NewError(fileName + " exists but is not a symlink")
+		}
+		if cur, err := os.Readlink(target); err == nil && cur == linkTarget {
+			return result.NewUnchanged(fileName + " already points to " + linkTarget)
+		}
+		if x.options.KeepExisting {
+			return result.NewUnchanged(fileName + " already exists and points to another file")
+		}
+		if err := os.Remove(target); err != nil {
+			return result.FromError(err, "Can't update "+fileName)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return result.FromError(err, fileName+" creation error")
+	}
+	if err := os.Symlink(linkTarget, target); err != nil {
+		return result.FromError(err, fileName+" creation error")
+	}
+
+	if exists {
+		return result.NewUpdated(fileName + " updated, now pointing to " + linkTarget)
+	}
+	return result.NewCreated(fileName + " created, pointing to " + linkTarget)
+}
+
+func (x *Extractor) extractFile(target, fileName string, mode os.FileMode, modTime time.Time, open func() (io.ReadCloser, error)) result.Result {
+	info, err := os.Lstat(target)
+	exists := err == nil
+	if exists && info.IsDir() {
+		return result.NewError(fileName + " exists but is a folder")
+	}
+	isRegular := exists && info.Mode().IsRegular()
+
+	// The content is written next to the target, then compared to the current one
+	tmp, err := filesystem.CreateAtomicFile(target)
+	if err != nil {
+		return result.FromError(err, fileName+" writing error")
+	}
+	defer tmp.Abort()
+
+	rc, err := open()
+	if err == nil {
+		_, err = io.Copy(tmp, rc)
+		rc.Close()
+	}
+	if err != nil {
+		return result.FromError(err, fileName+" writing error")
+	}
+
+	if isRegular {
+		same, err := filesystem.SameContent(target, tmp.Name())
+		if err != nil {
+			return result.FromError(err, fileName+" already exists but we can't read its content")
+		}
+		if same {
+			if info.Mode().Perm() == mode.Perm() {
+				return result.NewUnchanged(fileName + " already has expected content")
+			}
+			if err := os.Chmod(target, mode.Perm()); err != nil {
+				return result.FromError(err, "Can't update mode of "+fileName)
+			}
+			return result.NewUpdated(fileName + " mode updated to " + mode.Perm().String())
+		}
+	}
+	if exists && x.options.KeepExisting {
+		return result.NewUnchanged(fileName + " already has some user defined content")
+	}
+
+	// Rename replaces a symlink instead of writing where it points to
+	if err := tmp.Commit(mode.Perm(), modTime); err != nil {
+		return result.FromError(err, fileName+" writing error")
+	}
+
+	if exists {
+		return result.NewUpdated(fileName + " updated")
+	}
+	return result.NewCreated(fileName + " created")
+}
+
+// Finish sets the folder permissions once their content has been written,
+// so that read only folders can be extracted.
+// Folders are handled in reverse path order, so that subfolders are updated before their parent.
+func (x *Extractor) Finish() []result.Result {
+	var folders []string
+	for folder := range x.folderMode {
+		folders = append(folders, folder)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(folders)))
+
+	var results []result.Result
+	for _, folder := range folders {
+		mode := x.folderMode[folder]
+		info, err := os.Lstat(folder)
+		if err != nil || !info.IsDir() || info.Mode().Perm() == mode {
+			continue
+		}
+		if err := os.Chmod(folder, mode); err != nil {
+			results = append(results, result.FromError(err, "Can't update mode of "+folder))
+		}
+	}
+	return results
+}
+
+// =============================================
+
+// CheckName rejects entry names which would be extracted outside the destination folder.
+func CheckName(name string) error {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return errors.New("invalid entry name")
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return errors.New("invalid entry name")
+		}
+	}
+	return nil
+}
+
+func isInside(filePath, root string) bool {
+	filePath = filepath.Clean(filePath)
+	return filePath == root || strings.HasPrefix(filePath, root+string(filepath.Separator))
+}
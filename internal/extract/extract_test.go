@@ -0,0 +1,160 @@
+package extract
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entry is an archive entry to extract.
+type entry struct {
+	name    string
+	mode    os.FileMode
+	content string // file content, or symlink target
+}
+
+func extractEntries(t *testing.T, dest string, entries []entry) []bool {
+	t.Helper()
+	x, err := New(dest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failed []bool
+	for _, e := range entries {
+		content := e.content
+		open := func() (io.ReadCloser, error) { return ioutil.NopCloser(strings.NewReader(content)), nil }
+		res, _ := x.Extract(e.name, e.mode, time.Time{}, open)
+		failed = append(failed, res.IsError())
+	}
+	x.Finish()
+	return failed
+}
+
+// newDest creates a destination folder inside a parent folder holding a secret file.
+func newDest(t *testing.T) string {
+	t.Helper()
+	parent := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(parent, "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(parent, "dest")
+}
+
+func assertNotExist(t *testing.T, filePath string) {
+	t.Helper()
+	if _, err := os.Lstat(filePath); err == nil {
+		t.Errorf("%s should NOT exist", filePath)
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"", "../a", "a/../../b", "a/..", "/etc/passwd", "a\\..\\b"} {
+		if err := CheckName(name); err == nil {
+			t.Errorf("CheckName(%q) should fail", name)
+		}
+	}
+	for _, name := range []string{"a", "a/b/", "a..b", "./a"} {
+		if err := CheckName(name); err != nil {
+			t.Errorf("CheckName(%q) failed: %v", name, err)
+		}
+	}
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+	dest := newDest(t)
+	failed := extractEntries(t, dest, []entry{
+		{"../evil.txt", 0644, "evil"},
+		{"a/../../evil.txt", 0644, "evil"},
+		{"/tmp/evil.txt", 0644, "evil"},
+	})
+	for i, f := range failed {
+		if !f {
+			t.Errorf("entry %d should be rejected", i)
+		}
+	}
+	assertNotExist(t, filepath.Join(filepath.Dir(dest), "evil.txt"))
+}
+
+func TestExtractRejectsSymlinkTargets(t *testing.T) {
+	dest := newDest(t)
+	failed := extractEntries(t, dest, []entry{
+		{"abs", os.ModeSymlink | 0777, "/etc/passwd"},
+		{"up", os.ModeSymlink | 0777, "../secret.txt"},
+		{"sub/up", os.ModeSymlink | 0777, "../../secret.txt"},
+	})
+	for i, f := range failed {
+		if !f {
+			t.Errorf("entry %d should be rejected", i)
+		}
+	}
+	assertNotExist(t, filepath.Join(dest, "abs"))
+	assertNotExist(t, filepath.Join(dest, "up"))
+	assertNotExist(t, filepath.Join(dest, "sub", "up"))
+}
+
+func TestExtractRejectsChainedSymlinks(t *testing.T) {
+	dest := newDest(t)
+	failed := extractEntries(t, dest, []entry{
+		{"x", os.ModeSymlink | 0777, "."},
+		{"x/y", os.ModeSymlink | 0777, ".."},
+		{"z", os.ModeSymlink | 0777, "x/.."},
+	})
+	if failed[0] {
+		t.Error("x -> . should be accepted")
+	}
+	for i, f := range failed[1:] {
+		if !f {
+			t.Errorf("entry %d should be rejected", i+1)
+		}
+	}
+	assertNotExist(t, filepath.Join(dest, "y"))
+	assertNotExist(t, filepath.Join(dest, "z"))
+
+	content, err := ioutil.ReadFile(filepath.Join(filepath.Dir(dest), "secret.txt"))
+	if err != nil || string(content) != "secret" {
+		t.Errorf("secret.txt has been modified: %q, %v", content, err)
+	}
+}
+
+func TestExtractRejectsDelayedSymlinks(t *testing.T) {
+	dest := newDest(t)
+	// s/.. is dest while s is missing, but dest's parent once s -> . is extracted
+	failed := extractEntries(t, dest, []entry{
+		{"a", os.ModeSymlink | 0777, "s/.."},
+		{"s", os.ModeSymlink | 0777, "."},
+	})
+	if !failed[0] {
+		t.Error("a -> s/.. should be rejected")
+	}
+	assertNotExist(t, filepath.Join(dest, "a"))
+}
+
+func TestExtractAcceptsInnerSymlinks(t *testing.T) {
+	dest := newDest(t)
+	failed := extractEntries(t, dest, []entry{
+		{"lib/", os.ModeDir | 0755, ""},
+		{"lib/libfoo.so.1", 0644, "elf"},
+		{"lib/libfoo.so", os.ModeSymlink | 0777, "libfoo.so.1"},
+		{"bin/", os.ModeDir | 0755, ""},
+		{"bin/lib", os.ModeSymlink | 0777, "../lib"},
+		{"bin/lib/readme.txt", 0644, "through a symlink"},
+	})
+	for i, f := range failed {
+		if f {
+			t.Errorf("entry %d should be accepted", i)
+		}
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "lib", "libfoo.so"))
+	if err != nil || string(content) != "elf" {
+		t.Errorf("lib/libfoo.so: %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "lib", "readme.txt")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,89 @@
+package pathutil
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Path helpers shared by the filesystem, the archive extraction and the zip writer.
+
+// MatchGlob checks if a slash separated path matches a glob pattern.
+// The syntax is the one of path.Match, plus "**" which matches zero or more folders
+// (ie "META-INF/**/*.xml" matches "META-INF/a.xml" and "META-INF/maven/b/pom.xml").
+// A trailing slash in the name (folders in archives) is ignored.
+// The only possible error is path.ErrBadPattern.
+func MatchGlob(pattern, name string) (bool, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return false, err
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(strings.TrimSuffix(name, "/"), "/")), nil
+}
+
+// MatchPatterns checks if a slash separated relative path matches one of the glob patterns.
+// Patterns without "/" only match the base name.
+func MatchPatterns(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if matched, _ := MatchGlob(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// RealPath resolves the symlinks of the existing part of a path, the missing part is kept as is.
+func RealPath(filePath string) (string, error) {
+	existing, missing := filePath, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realExisting, missing), nil
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			// Consecutive "**" are the same as a single one
+			for len(pattern) != 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
@@ -16,7 +16,7 @@ func RunCmd(cmd *exec.Cmd, displayName string) result.Result {
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		return result.NewError(displayName + ": " + err.Error())
+		return result.FromError(err, displayName)
 	}
 
 	return result.NewUpdated(displayName)
@@ -37,12 +37,15 @@ func RunCmdStdIn(commandName, input string, cmd *exec.Cmd) result.Result {
 		return result.NewError(commandName + ": starting error (" + err.Error() + ")")
 	}
 
-	io.Copy(stdin, bytes.NewBufferString(input))
+	_, copyErr := io.Copy(stdin, bytes.NewBufferString(input))
 	stdin.Close()
 
 	err = cmd.Wait()
 	if err != nil {
 		return result.NewError(commandName + ": failed (" + err.Error() + ")")
 	}
+	if copyErr != nil {
+		return result.NewError(commandName + ": can't write stdin (" + copyErr.Error() + ")")
+	}
 	return result.NewUpdated(commandName)
 }
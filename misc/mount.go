@@ -1,7 +1,16 @@
 package misc
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gandrille/go-commons/result"
 )
 
 func IsMounted(path string) (bool, error) {
@@ -19,3 +28,271 @@ func IsMounted(path string) (bool, error) {
 		return false, err
 	}
 }
+
+const mountInfoFile = "/proc/self/mountinfo"
+const mountExe = "/bin/mount"
+const umountExe = "/bin/umount"
+
+// Mount is an entry of the mount table.
+// See proc(5) for the meaning of each field.
+type Mount struct {
+	ID           int
+	ParentID     int
+	Device       string   // major:minor
+	Root         string   // root of the mount within the filesystem
+	Target       string   // mount point
+	Options      []string // per mount options
+	Propagation  []string // optional fields (shared:N, master:N,...)
+	FsType       string
+	Source       string
+	SuperOptions []string // per superblock options
+}
+
+// MountTable is the list of mounts, in mount order.
+type MountTable []Mount
+
+// HasOption checks if an option is set, either on the mount or on the superblock.
+func (mount Mount) HasOption(option string) bool {
+	for _, opt := range append(mount.Options, mount.SuperOptions...) {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// IsShared checks if mount events are propagated to peers.
+func (mount Mount) IsShared() bool {
+	for _, field := range mount.Propagation {
+		if strings.HasPrefix(field, "shared:") {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadMountTable reads the mount table of the current process.
+func ReadMountTable() (MountTable, error) {
+	content, err := ioutil.ReadFile(mountInfoFile)
+	if err != nil {
+		return nil, errors.New("Can't read " + mountInfoFile + ": " + err.Error())
+	}
+	return ParseMountInfo(string(content))
+}
+
+// ParseMountInfo parses the content of a /proc/<pid>/mountinfo file.
+func ParseMountInfo(content string) (MountTable, error) {
+	var table MountTable
+
+	for i, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		sep := -1
+		for j := 6; j < len(fields); j++ {
+			if fields[j] == "-" {
+				sep = j
+				break
+			}
+		}
+		if sep == -1 || len(fields) < sep+3 {
+			return nil, errors.New("Malformed mountinfo line " + strconv.Itoa(i+1) + ": " + line)
+		}
+
+		id, err1 := strconv.Atoi(fields[0])
+		parentID, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("Malformed mount ID on mountinfo line " + strconv.Itoa(i+1) + ": " + line)
+		}
+
+		mount := Mount{
+			ID:          id,
+			ParentID:    parentID,
+			Device:      fields[2],
+			Root:        unescapeMountField(fields[3]),
+			Target:      unescapeMountField(fields[4]),
+			Options:     strings.Split(fields[5], ","),
+			Propagation: fields[6:sep],
+			FsType:      fields[sep+1],
+			Source:      unescapeMountField(fields[sep+2]),
+		}
+		if len(fields) > sep+3 {
+			mount.SuperOptions = strings.Split(fields[sep+3], ",")
+		}
+		table = append(table, mount)
+	}
+
+	return table, nil
+}
+
+// FindByTarget gets the mount visible at a mount point.
+// If several filesystems are mounted on top of each other, the last one is returned.
+func (table MountTable) FindByTarget(target string) (Mount, bool) {
+	target = filepath.Clean(target)
+	for i := len(table) - 1; i >= 0; i-- {
+		if table[i].Target == target {
+			return table[i], true
+		}
+	}
+	return Mount{}, false
+}
+
+// FindBySource gets all the mounts of a source.
+// The source can be a device path (symbolic links are resolved), UUID=xxx or LABEL=xxx.
+func (table MountTable) FindBySource(source string) []Mount {
+	resolved := resolveMountSource(source)
+
+	var mounts []Mount
+	for _, mount := range table {
+		if resolveMountSource(mount.Source) == resolved {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+// FindByUUID gets all the mounts of the device with a given filesystem UUID.
+func (table MountTable) FindByUUID(uuid string) []Mount {
+	return table.FindBySource("UUID=" + uuid)
+}
+
+// EnsureMounted mounts source on target unless it is already done.
+// fstype can be empty to let mount guess it.
+// If source is already mounted on target without all the options, it is remounted.
+// Options are compared with the ones reported by the kernel, so use their canonical form (ie size=1024k).
+// Options which are only meaningful in fstab (ie nofail, noauto, user, _netdev, x-systemd.*) are ignored.
+// An error is reported if another source is mounted on target.
+func EnsureMounted(source, target, fstype string, options []string) result.Result {
+	table, err := ReadMountTable()
+	if err != nil {
+		return result.FromError(err, "")
+	}
+
+	if mount, found := table.FindByTarget(target); found {
+		if resolveMountSource(mount.Source) != resolveMountSource(source) {
+			return result.NewError(target + " is already used by " + mount.Source + " instead of " + source)
+		}
+		if fstype != "" && fstype != mount.FsType {
+			return result.NewError(source + " is mounted on " + target + " as " + mount.FsType + " instead of " + fstype)
+		}
+
+		var missing []string
+		for _, option := range options {
+			if isKernelOption(option) && !mount.HasOption(option) {
+				missing = append(missing, option)
+			}
+		}
+		if len(missing) == 0 {
+			return result.NewUnchanged(source + " already mounted on " + target)
+		}
+
+		opts := "remount," + strings.Join(missing, ",")
+		if out, err := exec.Command(mountExe, "-o", opts, target).CombinedOutput(); err != nil {
+			return result.FromError(mountError(err, out), "Can't remount "+target+" with "+strings.Join(missing, ","))
+		}
+		return result.NewUpdated(target + " remounted with " + strings.Join(missing, ","))
+	}
+
+	if stat, err := os.Stat(target); err != nil {
+		return result.FromError(err, "Mount point "+target+" is not available")
+	} else if !stat.IsDir() {
+		return result.NewError("Mount point " + target + " is not a folder")
+	}
+
+	params := []string{}
+	if fstype != "" {
+		params = append(params, "-t", fstype)
+	}
+	if len(options) != 0 {
+		params = append(params, "-o", strings.Join(options, ","))
+	}
+	params = append(params, source, target)
+
+	if out, err := exec.Command(mountExe, params...).CombinedOutput(); err != nil {
+		return result.FromError(mountError(err, out), "Can't mount "+source+" on "+target)
+	}
+	return result.NewCreated(source + " mounted on " + target)
+}
+
+// EnsureUnmounted unmounts target if something is mounted on it.
+func EnsureUnmounted(target string) result.Result {
+	table, err := ReadMountTable()
+	if err != nil {
+		return result.FromError(err, "")
+	}
+
+	mount, found := table.FindByTarget(target)
+	if !found {
+		return result.NewUnchanged(target + " is not mounted")
+	}
+
+	if out, err := exec.Command(umountExe, target).CombinedOutput(); err != nil {
+		return result.FromError(mountError(err, out), "Can't unmount "+target)
+	}
+	return result.NewRemoved(mount.Source + " unmounted from " + target)
+}
+
+// resolveMountSource converts UUID=xxx and LABEL=xxx to device paths,
+// and resolves symbolic links (ie /dev/disk/by-id/xxx).
+func resolveMountSource(source string) string {
+	switch {
+	case strings.HasPrefix(source, "UUID="):
+		source = "/dev/disk/by-uuid/" + strings.TrimPrefix(source, "UUID=")
+	case strings.HasPrefix(source, "LABEL="):
+		source = "/dev/disk/by-label/" + strings.TrimPrefix(source, "LABEL=")
+	case strings.HasPrefix(source, "PARTUUID="):
+		source = "/dev/disk/by-partuuid/" + strings.TrimPrefix(source, "PARTUUID=")
+	}
+
+	if strings.HasPrefix(source, "/") {
+		if resolved, err := filepath.EvalSymlinks(source); err == nil {
+			return resolved
+		}
+	}
+	return source
+}
+
+// unescapeMountField decodes octal escapes (ie \040 for a space).
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, "\\") {
+		return field
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if value, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(field[i])
+	}
+	return sb.String()
+}
+
+// mountError wraps the error of a mount command, with its output as message if any.
+func mountError(err error, out []byte) error {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return fmt.Errorf("%s: %w", msg, err)
+	}
+	return err
+}
+
+// isKernelOption checks if a mount option is reported by the kernel once mounted.
+// The other ones are only used by mount, fstab or systemd.
+func isKernelOption(option string) bool {
+	switch option {
+	case "defaults", "auto", "noauto", "nofail", "user", "nouser", "users", "owner", "group", "_netdev", "bootwait", "nobootwait":
+		return false
+	}
+	for _, prefix := range []string{"x-", "X-", "comment="} {
+		if strings.HasPrefix(option, prefix) {
+			return false
+		}
+	}
+	return true
+}
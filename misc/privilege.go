@@ -0,0 +1,146 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"sync"
+)
+
+const sudoExe = "/usr/bin/sudo"
+const pkexecExe = "/usr/bin/pkexec"
+const runuserExe = "/usr/sbin/runuser"
+const envExe = "/usr/bin/env"
+
+// Escalation is the tool used for running commands as another user.
+// When running as root, runuser is always used.
+type Escalation int
+
+const (
+	// Sudo caches credentials: the password is asked only once (see Authenticate).
+	// Pkexec does NOT cache credentials, so group the work into as few commands as possible.
+	Sudo Escalation = iota
+	// Pkexec relies on polkit rules: the password may be asked for each command.
+	Pkexec
+)
+
+var escalation = Sudo
+var authMutex sync.Mutex
+var authDone bool
+var authErr error
+
+// SetEscalation selects the tool used for running commands as another user.
+func SetEscalation(tool Escalation) {
+	escalation = tool
+}
+
+// IsRoot checks if the current process is running as root.
+func IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+// IsCurrentUser checks if a user is the one running the current process.
+func IsCurrentUser(username string) bool {
+	current, err := user.Current()
+	return err == nil && current.Username == username
+}
+
+// Authenticate asks for the sudo password, once for the whole process.
+// Later calls refresh the sudo timestamp without prompting, so that long runs
+// outliving sudo's timestamp_timeout keep working. The password is asked again
+// only if the timestamp has expired anyway. A failed authentication is NOT retried.
+// Nothing is done if the current process is running as root, or with pkexec.
+func Authenticate() error {
+	if IsRoot() || escalation != Sudo {
+		return nil
+	}
+
+	authMutex.Lock()
+	defer authMutex.Unlock()
+
+	if authErr != nil {
+		return authErr
+	}
+	if authDone && exec.Command(sudoExe, "-n", "-v").Run() == nil {
+		return nil
+	}
+
+	cmd := exec.Command(sudoExe, "-v")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		authErr = errors.New("sudo authentication failed: " + err.Error())
+	}
+	authDone = true
+	return authErr
+}
+
+// EscalateCmd rewrites a command so that it runs as another user ("root" for root).
+// Dir and standard streams are kept, but NOT Env as escalation tools reset the environment.
+// The command is returned unchanged if it already runs as this user.
+func EscalateCmd(username string, cmd *exec.Cmd) (*exec.Cmd, error) {
+	if IsCurrentUser(username) {
+		return cmd, nil
+	}
+
+	name, args, err := escalatedCommandLine(username, cmd.Path, cmd.Args[1:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	escalated := exec.Command(name, args...)
+	escalated.Dir = cmd.Dir
+	escalated.Stdin = cmd.Stdin
+	escalated.Stdout = cmd.Stdout
+	escalated.Stderr = cmd.Stderr
+	return escalated, nil
+}
+
+// RunAs executes a command as another user ("root" for root), see Run.
+// Escalation is skipped if the command already runs as this user.
+func RunAs(ctx context.Context, username string, options CmdOptions, name string, args ...string) CmdResult {
+	if IsCurrentUser(username) {
+		return Run(ctx, options, name, args...)
+	}
+
+	newName, newArgs, err := escalatedCommandLine(username, name, args, options.Env)
+	if err != nil {
+		return CmdResult{-1, "", "", 0, err}
+	}
+
+	// environment overrides are passed on the command line
+	options.Env = nil
+	return Run(ctx, options, newName, newArgs...)
+}
+
+// RunAsRoot executes a command as root, see Run.
+// Escalation is skipped if the current process is running as root.
+func RunAsRoot(ctx context.Context, options CmdOptions, name string, args ...string) CmdResult {
+	return RunAs(ctx, "root", options, name, args...)
+}
+
+// escalatedCommandLine computes the command line running name as username.
+// env contains the KEY=VALUE pairs to set for the command, escalation tools resetting the environment.
+func escalatedCommandLine(username, name string, args []string, env []string) (string, []string, error) {
+	command := append([]string{name}, args...)
+	if len(env) != 0 {
+		command = append(append([]string{envExe}, env...), command...)
+	}
+
+	if IsRoot() {
+		return runuserExe, append([]string{"-u", username, "--"}, command...), nil
+	}
+
+	switch escalation {
+	case Pkexec:
+		return pkexecExe, append([]string{"--user", username}, command...), nil
+	default:
+		if err := Authenticate(); err != nil {
+			return "", nil, err
+		}
+		return sudoExe, append([]string{"-n", "-u", username, "--"}, command...), nil
+	}
+}
@@ -0,0 +1,91 @@
+package misc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gandrille/go-commons/result"
+)
+
+// RetryPolicy describes how a failing operation is retried.
+type RetryPolicy struct {
+	Attempts   int                      // maximum number of attempts, including the first one
+	Delay      time.Duration            // delay before the second attempt
+	MaxDelay   time.Duration            // upper bound of the delay, no bound if 0
+	Factor     float64                  // delay multiplier between two attempts, 2 if 0
+	RetryIf    func(result.Result) bool // checks if a failed Result is worth retrying, all failures if nil
+	RetryCmdIf func(CmdResult) bool     // checks if a failed CmdResult is worth retrying, all failures if nil
+}
+
+// DefaultRetryPolicy makes 3 attempts, waiting 1s then 2s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Attempts: 3, Delay: time.Second, Factor: 2}
+}
+
+// RetryOnExitCodes builds a RetryCmdIf predicate accepting only some exit codes.
+func RetryOnExitCodes(codes ...int) func(CmdResult) bool {
+	return func(res CmdResult) bool {
+		for _, code := range codes {
+			if res.ExitCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Retry executes runner until it succeeds, the policy refuses to retry, or the attempts are exhausted.
+// The number of attempts is appended to the message of the final result.
+func Retry(policy RetryPolicy, runner func() result.Result) result.Result {
+	var res result.Result
+	attempt := 1
+	for ; ; attempt++ {
+		res = runner()
+		if res.IsSuccess() || attempt >= policy.Attempts || (policy.RetryIf != nil && !policy.RetryIf(res)) {
+			break
+		}
+		time.Sleep(policy.delay(attempt))
+	}
+
+	res = res.WithMessage(res.Message() + " (" + attempts(attempt) + ")")
+	return res
+}
+
+// RetryCmd executes runner until the command succeeds, the policy refuses to retry, or the attempts are exhausted.
+// Returns the final command outcome and the number of attempts.
+func RetryCmd(policy RetryPolicy, runner func() CmdResult) (CmdResult, int) {
+	var res CmdResult
+	attempt := 1
+	for ; ; attempt++ {
+		res = runner()
+		if res.IsSuccess() || attempt >= policy.Attempts || (policy.RetryCmdIf != nil && !policy.RetryCmdIf(res)) {
+			break
+		}
+		time.Sleep(policy.delay(attempt))
+	}
+	return res, attempt
+}
+
+// delay computes the time to wait after a failed attempt (starting at 1).
+func (policy RetryPolicy) delay(attempt int) time.Duration {
+	factor := policy.Factor
+	if factor == 0 {
+		factor = 2
+	}
+
+	delay := float64(policy.Delay)
+	for i := 1; i < attempt; i++ {
+		delay *= factor
+		if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+			return policy.MaxDelay
+		}
+	}
+	return time.Duration(delay)
+}
+
+func attempts(n int) string {
+	if n == 1 {
+		return "1 attempt"
+	}
+	return strconv.Itoa(n) + " attempts"
+}
@@ -0,0 +1,162 @@
+package misc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gandrille/go-commons/result"
+)
+
+// defaultKillDelay is the time given to a command to exit after SIGTERM, before SIGKILL is sent.
+const defaultKillDelay = 5 * time.Second
+
+// stderrTailLines is the number of stderr lines reported in a failed Result.
+const stderrTailLines = 5
+
+// CmdOptions configures the execution of a command.
+// The zero value runs the command in the current folder, with the current environment and no timeout.
+type CmdOptions struct {
+	Dir       string        // working directory
+	Env       []string      // KEY=VALUE pairs overriding the current environment
+	Stdin     string        // content sent to the standard input
+	Timeout   time.Duration // no timeout if 0
+	KillDelay time.Duration // delay between SIGTERM and SIGKILL, 5s if 0
+	Tee       bool          // also copy stdout and stderr to the terminal
+}
+
+// CmdResult is the outcome of a command execution.
+type CmdResult struct {
+	ExitCode int // -1 if the command has not exited normally
+	Stdout   string
+	Stderr   string
+	Duration time.Duration
+	Err      error // nil if the command has exited with code 0, wraps context.DeadlineExceeded on timeout
+}
+
+// Run executes a command and captures its output.
+// When ctx is cancelled or the timeout expires, the command receives SIGTERM,
+// then SIGKILL if it is still running after options.KillDelay.
+func Run(ctx context.Context, options CmdOptions, name string, args ...string) CmdResult {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = options.Dir
+	if options.Timeout > 0 || ctx.Done() != nil {
+		// own process group, so that signals also reach the children of the command.
+		// Not done otherwise, as it prevents the command from reading the terminal (ie password prompts).
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
+	if len(options.Env) != 0 {
+		cmd.Env = append(os.Environ(), options.Env...)
+	}
+	if options.Stdin != "" {
+		cmd.Stdin = strings.NewReader(options.Stdin)
+	}
+	if options.Tee {
+		cmd.Stdout = io.MultiWriter(&stdout, os.Stdout)
+		cmd.Stderr = io.MultiWriter(&stderr, os.Stderr)
+	} else {
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+	}
+
+	t0 := time.Now()
+	if err := cmd.Start(); err != nil {
+		return CmdResult{-1, "", "", time.Since(t0), err}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err, reason error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		reason = fmt.Errorf("interrupted: %w", ctx.Err())
+		err = terminate(cmd, done, options.KillDelay)
+	case <-timeout:
+		reason = fmt.Errorf("timeout after %s: %w", options.Timeout, context.DeadlineExceeded)
+		err = terminate(cmd, done, options.KillDelay)
+	}
+	duration := time.Since(t0)
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	if reason != nil {
+		err = reason
+	}
+
+	return CmdResult{exitCode, stdout.String(), stderr.String(), duration, err}
+}
+
+// terminate sends SIGTERM, then SIGKILL after killDelay, to the process group of the command,
+// and waits for the command to exit.
+func terminate(cmd *exec.Cmd, done chan error, killDelay time.Duration) error {
+	if killDelay <= 0 {
+		killDelay = defaultKillDelay
+	}
+
+	pgid := -cmd.Process.Pid
+	if cmd.SysProcAttr == nil || !cmd.SysProcAttr.Setpgid {
+		pgid = cmd.Process.Pid
+	}
+	if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil {
+		syscall.Kill(pgid, syscall.SIGKILL)
+		return <-done
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(killDelay):
+		syscall.Kill(pgid, syscall.SIGKILL)
+		return <-done
+	}
+}
+
+// IsSuccess checks if the command has exited with code 0.
+func (res CmdResult) IsSuccess() bool {
+	return res.Err == nil
+}
+
+// StderrTail gets the last lines of stderr.
+func (res CmdResult) StderrTail(lines int) string {
+	all := strings.Split(strings.TrimRight(res.Stderr, "\n"), "\n")
+	if len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
+
+// Result converts the command outcome to a Result.
+// On success, the result has the given status.
+// On failure, the last lines of stderr are appended to the message.
+func (res CmdResult) Result(displayName string, success result.Status) result.Result {
+	if res.IsSuccess() {
+		return result.New(success, displayName)
+	}
+
+	failure := result.FromError(res.Err, displayName)
+	if tail := res.StderrTail(stderrTailLines); tail != "" {
+		failure = failure.WithMessage(failure.Message() + "\n" + tail)
+	}
+	return failure
+}
@@ -9,6 +9,7 @@ import (
 var green = color.New(color.FgGreen).SprintFunc()
 var red = color.New(color.FgRed).SprintFunc()
 var cyan = color.New(color.FgCyan).SprintFunc()
+var yellow = color.New(color.FgYellow).SprintFunc()
 
 // PrintOK prints a success message.
 // A newline is appended.
@@ -37,9 +38,13 @@ func PrintInfo(message string) {
 // Describe prints the name in color, and the shortDesc using normal color.
 // A newline is appended.
 func Describe(name, shortDesc string) {
+	describeIndented("", name, shortDesc)
+}
+
+func describeIndented(indent, name, shortDesc string) {
 	if shortDesc == "" {
-		fmt.Printf("%s\n", cyan(name))
+		fmt.Printf("%s%s\n", indent, cyan(name))
 	} else {
-		fmt.Printf("%s %s\n", cyan(name), shortDesc)
+		fmt.Printf("%s%s %s\n", indent, cyan(name), shortDesc)
 	}
 }
@@ -0,0 +1,182 @@
+package result
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export of result sets for continuous integration tools.
+// Each Result is a test case:
+// Error is a failure, Skipped is skipped, other statuses are passed.
+// The status and the full message are available in the test case output.
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+// WriteJUnit writes the set as a JUnit XML report.
+// The results of each child set are written in their own test suite, named after the path of the group (ie "GNOME/Shell").
+// The results directly stored in the set are written in a test suite called name.
+func (results Set) WriteJUnit(w io.Writer, name string) error {
+	report := junitTestSuites{Name: name}
+
+	var total time.Duration
+	for _, group := range results.flatten(name) {
+		suite := junitTestSuite{Name: group.path}
+		var suiteTime time.Duration
+
+		for _, res := range group.results {
+			testCase := junitTestCase{
+				Name:      firstLine(res.message),
+				ClassName: group.path,
+				Time:      seconds(res.duration),
+				SystemOut: "[" + strings.ToUpper(res.status.String()) + "] " + res.message,
+			}
+			switch {
+			case res.IsError():
+				testCase.Failure = &junitMessage{firstLine(res.message), res.status.String(), res.message}
+				suite.Failures++
+			case res.IsSkipped():
+				testCase.Skipped = &junitMessage{firstLine(res.message), "", ""}
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+			suiteTime += res.duration
+		}
+
+		suite.Time = seconds(suiteTime)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		total += suiteTime
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteTAP writes the set using the Test Anything Protocol, version 13.
+// Child sets are introduced by a comment line with the path of the group.
+// A YAML block gives the status, the full message and the duration when relevant.
+func (results Set) WriteTAP(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("TAP version 13\n")
+	sb.WriteString("1.." + strconv.Itoa(results.Size()) + "\n")
+
+	idx := 0
+	for _, group := range results.flatten("") {
+		if group.path != "" && len(group.results) != 0 {
+			sb.WriteString("# " + group.path + "\n")
+		}
+
+		for _, res := range group.results {
+			idx++
+			description := strings.Replace(firstLine(res.message), "#", "\\#", -1)
+
+			if res.IsError() {
+				sb.WriteString("not ok " + strconv.Itoa(idx) + " - " + description + "\n")
+			} else {
+				sb.WriteString("ok " + strconv.Itoa(idx) + " - " + description)
+				if res.IsSkipped() {
+					sb.WriteString(" # SKIP")
+				}
+				sb.WriteString("\n")
+			}
+
+			if res.IsError() || res.IsWarning() || res.duration != 0 || strings.Contains(res.message, "\n") {
+				sb.WriteString("  ---\n")
+				sb.WriteString("  status: " + res.status.String() + "\n")
+				sb.WriteString("  message: " + strconv.Quote(res.message) + "\n")
+				if res.duration != 0 {
+					sb.WriteString(fmt.Sprintf("  duration_ms: %d\n", res.duration.Milliseconds()))
+				}
+				sb.WriteString("  ...\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// resultGroup is the list of results directly stored in a set.
+type resultGroup struct {
+	path    string
+	results []Result
+}
+
+// flatten lists the results of the set and its children, set by set.
+func (results Set) flatten(path string) []resultGroup {
+	groups := []resultGroup{{path, nil}}
+	for _, entry := range results.entries {
+		if entry.set == nil {
+			groups[0].results = append(groups[0].results, entry.result)
+			continue
+		}
+
+		childPath := entry.set.name
+		if path != "" {
+			childPath = path + "/" + childPath
+		}
+		groups = append(groups, entry.set.flatten(childPath)...)
+	}
+
+	if len(groups[0].results) == 0 && len(groups) > 1 {
+		return groups[1:]
+	}
+	return groups
+}
+
+func firstLine(message string) string {
+	return strings.SplitN(message, "\n", 2)[0]
+}
+
+func seconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,179 @@
+package result
+
+import (
+	"strings"
+)
+
+// Graph is a set of tasks with dependencies.
+// Tasks are executed one after the other in topological order.
+// A task is Skipped if one of its prerequisites is in Error or Skipped.
+// Handlers are tasks executed only if a task notifying them has Created or Updated something
+// (ie restarting a service only if its configuration file has changed).
+type Graph struct {
+	nodes    []*graphNode
+	notifies map[string][]string // handlers notified by each task
+}
+
+type graphNode struct {
+	task      Task
+	deps      []string
+	isHandler bool
+}
+
+// NewGraph constructs an empty Graph object.
+func NewGraph() *Graph {
+	return &Graph{nil, map[string][]string{}}
+}
+
+// Add adds a task, executed after all the tasks it depends on.
+// The graph is returned for convenience.
+func (graph *Graph) Add(task Task, dependsOn ...string) *Graph {
+	graph.nodes = append(graph.nodes, &graphNode{task, dependsOn, false})
+	return graph
+}
+
+// AddHandler adds a task executed only when notified, after all the tasks notifying it.
+// The graph is returned for convenience.
+func (graph *Graph) AddHandler(task Task, dependsOn ...string) *Graph {
+	graph.nodes = append(graph.nodes, &graphNode{task, dependsOn, true})
+	return graph
+}
+
+// Notify registers handlers to trigger when a task has Created or Updated something.
+// The graph is returned for convenience.
+func (graph *Graph) Notify(taskName string, handlerNames ...string) *Graph {
+	graph.notifies[taskName] = append(graph.notifies[taskName], handlerNames...)
+	return graph
+}
+
+// Run executes the tasks in topological order.
+// Tasks without dependencies between them are executed in insertion order.
+// If the graph is invalid (unknown task, duplicated name or cycle), a single Error result is returned.
+func (graph *Graph) Run() Set {
+	order, err := graph.sort()
+	if err != "" {
+		return NewSet([]Result{NewError(err)}, "")
+	}
+
+	// notifiers of each handler
+	notifiers := map[string][]string{}
+	for _, node := range graph.nodes {
+		for _, handler := range graph.notifies[node.task.Name] {
+			notifiers[handler] = append(notifiers[handler], node.task.Name)
+		}
+	}
+
+	results := NewSet(nil, "")
+	done := map[string]Result{}
+	for _, node := range order {
+		res := graph.runNode(node, done, notifiers[node.task.Name])
+		done[node.task.Name] = res
+		results.Add(res)
+	}
+	return results
+}
+
+func (graph *Graph) runNode(node *graphNode, done map[string]Result, notifiers []string) Result {
+	name := node.task.Name
+
+	for _, dep := range node.deps {
+		if res := done[dep]; res.IsError() || res.IsSkipped() {
+			return NewSkipped(name + " skipped because prerequisite " + dep + " has not been executed with success")
+		}
+	}
+
+	if node.isHandler {
+		notified := false
+		for _, notifier := range notifiers {
+			if res := done[notifier]; res.IsCreated() || res.IsUpdated() {
+				notified = true
+			}
+		}
+		if !notified {
+			return NewSkipped(name + " skipped because nothing has changed")
+		}
+	}
+
+	return runTask(node.task)
+}
+
+// sort computes the execution order, or an error message.
+func (graph *Graph) sort() ([]*graphNode, string) {
+	names := map[string]bool{}
+	for _, node := range graph.nodes {
+		if names[node.task.Name] {
+			return nil, "Task " + node.task.Name + " is defined several times"
+		}
+		names[node.task.Name] = true
+	}
+	for name := range graph.notifies {
+		if !names[name] {
+			return nil, "Task " + name + " notifies handlers but is not defined"
+		}
+	}
+
+	// all the edges: dependencies, and notifiers before handlers
+	prerequisites := map[string][]string{}
+	for _, node := range graph.nodes {
+		for _, dep := range node.deps {
+			if !names[dep] {
+				return nil, "Task " + node.task.Name + " depends on unknown task " + dep
+			}
+			prerequisites[node.task.Name] = append(prerequisites[node.task.Name], dep)
+		}
+		for _, handler := range graph.notifies[node.task.Name] {
+			target := graph.node(handler)
+			if target == nil {
+				return nil, "Task " + node.task.Name + " notifies unknown handler " + handler
+			}
+			if !target.isHandler {
+				return nil, "Task " + node.task.Name + " notifies " + handler + " which is not a handler"
+			}
+			prerequisites[handler] = append(prerequisites[handler], node.task.Name)
+		}
+	}
+
+	// picking the first ready node in insertion order, until all nodes are scheduled
+	var order []*graphNode
+	scheduled := map[string]bool{}
+	for len(order) < len(graph.nodes) {
+		progress := false
+		for _, node := range graph.nodes {
+			if scheduled[node.task.Name] || !allScheduled(prerequisites[node.task.Name], scheduled) {
+				continue
+			}
+			order = append(order, node)
+			scheduled[node.task.Name] = true
+			progress = true
+			break
+		}
+		if !progress {
+			var cycle []string
+			for _, node := range graph.nodes {
+				if !scheduled[node.task.Name] {
+					cycle = append(cycle, node.task.Name)
+				}
+			}
+			return nil, "Dependency cycle between tasks " + strings.Join(cycle, ", ")
+		}
+	}
+	return order, ""
+}
+
+func (graph *Graph) node(name string) *graphNode {
+	for _, node := range graph.nodes {
+		if node.task.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+func allScheduled(names []string, scheduled map[string]bool) bool {
+	for _, name := range names {
+		if !scheduled[name] {
+			return false
+		}
+	}
+	return true
+}
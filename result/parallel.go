@@ -0,0 +1,97 @@
+package result
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Task is a named treatment producing a Result.
+type Task struct {
+	Name string
+	Run  func() Result
+}
+
+// ParallelOptions configures RunParallel.
+type ParallelOptions struct {
+	Workers  int  // maximum number of tasks running at the same time, number of CPUs if 0
+	FailFast bool // tasks not started yet are cancelled after the first error
+	Progress bool // prints each result as soon as it is available
+}
+
+// NewTask constructs a Task object.
+func NewTask(name string, runner func() Result) Task {
+	return Task{name, runner}
+}
+
+// RunParallel executes independent tasks concurrently.
+// The results are stored in the Set in the same order as the tasks,
+// whatever the order in which they have been executed.
+// A panicking task produces an Error result.
+func RunParallel(tasks []Task, options ParallelOptions) Set {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]Result, len(tasks))
+	indexes := make(chan int)
+
+	var mutex sync.Mutex
+	failed := false
+	done := 0
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				mutex.Lock()
+				cancelled := options.FailFast && failed
+				mutex.Unlock()
+
+				var res Result
+				if cancelled {
+					res = NewSkipped(tasks[idx].Name + " NOT executed because a previous task failed")
+				} else {
+					res = runTask(tasks[idx])
+				}
+
+				mutex.Lock()
+				results[idx] = res
+				failed = failed || res.IsFailure()
+				done++
+				if options.Progress {
+					fmt.Printf("%s ", cyan(fmt.Sprintf("[%d/%d]", done, len(tasks))))
+					res.Print()
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for idx := range tasks {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	return NewSet(results, "")
+}
+
+// runTask executes a task, converting a panic into an Error result.
+// The time spent is stored in the result, unless the task has already done it.
+func runTask(task Task) (res Result) {
+	t0 := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			res = NewError(fmt.Sprintf("%s: panic: %v", task.Name, r))
+		}
+		if res.duration == 0 {
+			res.duration = time.Since(t0)
+		}
+	}()
+	return task.Run()
+}
@@ -1,6 +1,7 @@
 package result
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -8,8 +9,13 @@ import (
 
 // Result type
 type Result struct {
-	status  Status
-	message string
+	status   Status
+	message  string
+	restart  Restart
+	err      error
+	duration time.Duration
+	subject  string // what the result is about (ie a file path), for audit
+	diff     string // the change, for audit
 }
 
 // Status
@@ -22,6 +28,8 @@ const (
 	Removed
 	Info
 	Error
+	Skipped
+	Warning
 )
 
 func (s Status) String() string {
@@ -35,6 +43,8 @@ var toString = map[Status]string{
 	Removed:   "Removed",
 	Info:      "Info",
 	Error:     "Error",
+	Skipped:   "Skipped",
+	Warning:   "Warning",
 }
 
 var toID = map[string]Status{
@@ -44,19 +54,80 @@ var toID = map[string]Status{
 	"Removed":   Removed,
 	"Info":      Info,
 	"Error":     Error,
+	"Skipped":   Skipped,
+	"Warning":   Warning,
+}
+
+// ParseStatus gets a status from its name (ie "Created").
+func ParseStatus(name string) (Status, error) {
+	if status, ok := toID[name]; ok {
+		return status, nil
+	}
+	return Error, errors.New("Unknown status " + name)
+}
+
+// MarshalText encodes the status as its name.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a status name.
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := ParseStatus(string(text))
+	if err == nil {
+		*s = status
+	}
+	return err
+}
+
+// Restart tells what is needed for a change to be taken into account.
+type Restart int
+
+const (
+	NoRestart Restart = iota
+	Relogin
+	Reboot
+)
+
+func (r Restart) String() string {
+	return restartToString[r]
+}
+
+var restartToString = map[Restart]string{
+	NoRestart: "No restart",
+	Relogin:   "Relogin",
+	Reboot:    "Reboot",
+}
+
+// MarshalText encodes the restart requirement as its name.
+func (r Restart) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a restart requirement name.
+func (r *Restart) UnmarshalText(text []byte) error {
+	for restart, name := range restartToString {
+		if name == string(text) {
+			*r = restart
+			return nil
+		}
+	}
+	return errors.New("Unknown restart requirement " + string(text))
 }
 
 // New constructs a Result object.
 func New(status Status, message string) Result {
-	return Result{status, message}
+	return Result{status: status, message: message, restart: NoRestart}
 }
 
 // Run executes a treatement and append the time spent to the result message
+// The time spent is also available with Duration.
 func Run(runner func() Result) Result {
 	t0 := time.Now()
 	result := runner()
 	t1 := time.Now()
-	duration := t1.Sub(t0).Truncate(time.Second).String()
+	result.duration = t1.Sub(t0)
+	duration := result.duration.Truncate(time.Second).String()
 	result.SetMessage(fmt.Sprintf("%s (%s)", result.Message(), duration))
 	return result
 }
@@ -71,26 +142,95 @@ func (result Result) Status() Status {
 	return result.status
 }
 
+// Restart getter
+func (result Result) Restart() Restart {
+	return result.restart
+}
+
+// WithRestart gets a copy of the result requiring a relogin or a reboot.
+func (result Result) WithRestart(restart Restart) Result {
+	result.restart = restart
+	return result
+}
+
+// RequiresRestart checks if a relogin or a reboot is needed.
+func (result Result) RequiresRestart() bool {
+	return result.restart != NoRestart
+}
+
+// Err gets the wrapped error, if any.
+// A failed result does NOT always wrap an error.
+func (result Result) Err() error {
+	return result.err
+}
+
+// Duration gets the time spent to compute the result, 0 if unknown (see Run).
+func (result Result) Duration() time.Duration {
+	return result.duration
+}
+
+// WithDuration gets a copy of the result with the time spent to compute it.
+func (result Result) WithDuration(duration time.Duration) Result {
+	result.duration = duration
+	return result
+}
+
+// Subject gets what the result is about (ie an absolute file path or a key), empty if unknown.
+func (result Result) Subject() string {
+	return result.subject
+}
+
+// WithSubject gets a copy of the result about a subject (ie an absolute file path or a key).
+func (result Result) WithSubject(subject string) Result {
+	result.subject = subject
+	return result
+}
+
+// Diff gets a description of the change, empty if unknown.
+func (result Result) Diff() string {
+	return result.diff
+}
+
+// WithDiff gets a copy of the result with a description of the change (ie a text diff).
+func (result Result) WithDiff(diff string) Result {
+	result.diff = diff
+	return result
+}
+
+// WithMessage gets a copy of the result with another message.
+func (result Result) WithMessage(message string) Result {
+	result.message = message
+	return result
+}
+
+// WithErr gets a copy of the result wrapping an error.
+func (result Result) WithErr(err error) Result {
+	result.err = err
+	return result
+}
+
 // StandardizeMessage constructs a Result object with a standardized message
+// The name is also the subject of the result, unless it already has one.
 func (result Result) StandardizeMessage(name, value string) Result {
-	if result.IsCreated() {
-		return NewCreated(name + " created with value " + value)
-	}
-	if result.IsUpdated() {
-		return NewUpdated(name + " updated. Value is now " + value)
-	}
-	if result.IsUnchanged() {
-		return NewUnchanged(name + " has value " + value)
-	}
-	if result.IsRemoved() {
-		return NewRemoved(name + " has been deleted")
-	}
-	if result.IsRemoved() {
-		return NewInfo(name + " has value " + value)
+	if result.subject == "" {
+		result.subject = name
 	}
 
-	// we want to update the original message
-	result.SetMessage(name + ": " + result.Message())
+	switch {
+	case result.IsCreated():
+		result.message = name + " created with value " + value
+	case result.IsUpdated():
+		result.message = name + " updated. Value is now " + value
+	case result.IsUnchanged():
+		result.message = name + " has value " + value
+	case result.IsRemoved():
+		result.message = name + " has been deleted"
+	case result.IsInfo():
+		result.message = name + " has value " + value
+	default:
+		// we want to update the original message
+		result.message = name + ": " + result.message
+	}
 	return result
 }
 
@@ -98,37 +238,84 @@ func (result Result) StandardizeMessage(name, value string) Result {
 
 // NewCreated constructs a Created Result object
 func NewCreated(message string) Result {
-	return Result{Created, message}
+	return New(Created, message)
 }
 
 // NewUpdated constructs an Updated Result object
 func NewUpdated(message string) Result {
-	return Result{Updated, message}
+	return New(Updated, message)
 }
 
 // NewUnchanged constructs an Unchanged Result object
 func NewUnchanged(message string) Result {
-	return Result{Unchanged, message}
+	return New(Unchanged, message)
 }
 
 // NewRemoved constructs a Removed Result object
 func NewRemoved(message string) Result {
-	return Result{Removed, message}
+	return New(Removed, message)
 }
 
 // NewInfo constructs an Info Result object
 func NewInfo(message string) Result {
-	return Result{Info, message}
+	return New(Info, message)
 }
 
 // NewError constructs an Error Result object
 func NewError(message string) Result {
-	return Result{Error, message}
+	return New(Error, message)
+}
+
+// NewSkipped constructs a Skipped Result object
+// Use it when a precondition is not met.
+func NewSkipped(message string) Result {
+	return New(Skipped, message)
+}
+
+// NewWarning constructs a Warning Result object
+func NewWarning(message string) Result {
+	return New(Warning, message)
+}
+
+// FromError constructs an Error Result object wrapping an error.
+// The message is "prefix: error message", or only the error message if prefix is empty.
+// If err is nil, an Info Result object with prefix as message is returned.
+func FromError(err error, prefix string) Result {
+	if err == nil {
+		return NewInfo(prefix)
+	}
+	if prefix == "" {
+		return Result{status: Error, message: err.Error(), restart: NoRestart, err: err}
+	}
+	return Result{status: Error, message: prefix + ": " + err.Error(), restart: NoRestart, err: err}
+}
+
+// =============================================
+
+// Error gets the message, so that a Result can be used as an error.
+func (result Result) Error() string {
+	return result.message
+}
+
+// Unwrap gets the wrapped error, for errors.Is and errors.As.
+func (result Result) Unwrap() error {
+	return result.err
+}
+
+// ToError converts a failed result into an error, wrapping the original error if any.
+// Returns nil if the result is a success.
+// The Result can be retrieved with errors.As.
+func (result Result) ToError() error {
+	if result.IsSuccess() {
+		return nil
+	}
+	return result
 }
 
 // =============================================
 
 // IsSuccess function
+// Warning and Skipped results are successful.
 func (result Result) IsSuccess() bool {
 	return result.status != Error
 }
@@ -170,6 +357,16 @@ func (result Result) IsError() bool {
 	return result.status == Error
 }
 
+// IsSkipped function
+func (result Result) IsSkipped() bool {
+	return result.status == Skipped
+}
+
+// IsWarning function
+func (result Result) IsWarning() bool {
+	return result.status == Warning
+}
+
 // =============================================
 
 // SetMessage setter
@@ -179,12 +376,22 @@ func (result Result) SetMessage(message string) {
 
 // Print prints a result.
 func (result Result) Print() {
+	result.printIndented("")
+}
+
+func (result Result) printIndented(indent string) {
 	tag := strings.ToUpper("[" + result.status.String() + "]")
 	msg := result.message
+	if result.RequiresRestart() {
+		msg += " " + yellow("("+strings.ToLower(result.restart.String())+" required)")
+	}
 
-	if result.IsSuccess() {
-		fmt.Printf("%s %s\n", green(tag), msg)
-	} else {
-		fmt.Printf("%s %s\n", red(tag), msg)
+	switch {
+	case result.IsFailure():
+		fmt.Printf("%s%s %s\n", indent, red(tag), msg)
+	case result.IsWarning() || result.IsSkipped():
+		fmt.Printf("%s%s %s\n", indent, yellow(tag), msg)
+	default:
+		fmt.Printf("%s%s %s\n", indent, green(tag), msg)
 	}
 }
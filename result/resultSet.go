@@ -1,24 +1,92 @@
 package result
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // Set helps managing a set of Result
+// A set can contain child sets (groups), with their own name and summary.
 type Set struct {
-	results []Result
-	message string
+	entries     []setEntry
+	message     string
+	name        string
+	description string
 }
 
+// setEntry is either a result or a child set.
+type setEntry struct {
+	result Result
+	set    *Set
+}
+
+// Statistics gives the number of results for each status.
+type Statistics map[Status]int
+
 // NewSet constructor.
 func NewSet(results []Result, message string) Set {
-	return Set{results, message}
+	set := Set{nil, message, "", ""}
+	for _, res := range results {
+		set.Add(res)
+	}
+	return set
+}
+
+// NewGroup constructs an empty named set, to be added to a parent set.
+// The name and the description are printed as a header, using Describe formatting.
+func NewGroup(name, description string) Set {
+	return Set{nil, "", name, description}
+}
+
+// Name getter.
+func (results Set) Name() string {
+	return results.name
+}
+
+// Description getter.
+func (results Set) Description() string {
+	return results.description
+}
+
+// Results gets all the results, including the ones of the child sets.
+func (results Set) Results() []Result {
+	var list []Result
+	for _, entry := range results.entries {
+		if entry.set != nil {
+			list = append(list, entry.set.Results()...)
+		} else {
+			list = append(list, entry.result)
+		}
+	}
+	return list
+}
+
+// Children gets the child sets.
+func (results Set) Children() []Set {
+	var list []Set
+	for _, entry := range results.entries {
+		if entry.set != nil {
+			list = append(list, *entry.set)
+		}
+	}
+	return list
+}
+
+// Walk calls fn for each result, including the ones of the child sets.
+// group is the path of the child set holding the result (ie "GNOME/Shell"), empty for the results of this set.
+func (results Set) Walk(fn func(group string, res Result)) {
+	for _, group := range results.flatten("") {
+		for _, res := range group.results {
+			fn(group.path, res)
+		}
+	}
 }
 
 // IsSuccess checks if all the results are in success.
 func (results Set) IsSuccess() bool {
-	for _, element := range results.results {
+	for _, element := range results.Results() {
 		if !element.IsSuccess() {
 			return false
 		}
@@ -26,40 +94,109 @@ func (results Set) IsSuccess() bool {
 	return true
 }
 
+// Err gets an error joining all the failed results (see Result.ToError),
+// or nil if all the results are in success.
+func (results Set) Err() error {
+	var errs multiError
+	for _, element := range results.Results() {
+		if err := element.ToError(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
 // OverallResult gets a result object for the all set.
+// It is a Warning if at least one result is a Warning,
+// and requires the strongest restart of the results.
 func (results Set) OverallResult() Result {
-	if results.IsSuccess() {
-		return NewInfo(results.Message())
+	var res Result
+	if !results.IsSuccess() {
+		res = NewError(results.Message())
+	} else if results.Count(Warning) != 0 {
+		res = NewWarning(results.Message())
 	} else {
-		return NewError(results.Message())
+		res = NewInfo(results.Message())
 	}
+	return res.WithRestart(results.Restart())
+}
+
+// Count gets the number of results with a given status.
+func (results Set) Count(status Status) int {
+	return results.Statistics()[status]
+}
+
+// Statistics gets the number of results for each status, including the child sets.
+func (results Set) Statistics() Statistics {
+	stats := Statistics{}
+	for _, element := range results.Results() {
+		stats[element.status]++
+	}
+	return stats
+}
+
+// Restart gets the strongest restart requirement of the results.
+func (results Set) Restart() Restart {
+	restart := NoRestart
+	for _, element := range results.Results() {
+		if element.restart > restart {
+			restart = element.restart
+		}
+	}
+	return restart
 }
 
 // IsEmpty checks if the set contains NO result.
 func (results Set) IsEmpty() bool {
-	return len(results.results) == 0
+	return results.Size() == 0
 }
 
 // Size gets the number of results (both success and failures).
 func (results Set) Size() int {
-	return len(results.results)
+	return len(results.Results())
 }
 
 // Print prints a result.
+// Child sets are printed with a header, their results indented, and a summary.
 func (results Set) Print() {
-	for _, res := range results.results {
-		res.Print()
+	if results.name != "" {
+		Describe(results.name, results.description)
 	}
-	if !results.IsEmpty() {
+	results.printEntries("")
+	if len(results.entries) != 0 {
 		fmt.Println()
 	}
 	results.OverallResult().Print()
 }
 
+func (results Set) printEntries(indent string) {
+	for _, entry := range results.entries {
+		if entry.set == nil {
+			entry.result.printIndented(indent)
+			continue
+		}
+
+		child := entry.set
+		describeIndented(indent, child.name, child.description)
+		child.printEntries(indent + "  ")
+		child.OverallResult().printIndented(indent + "  ")
+	}
+}
+
 // Add a new result to the result set.
 // The result set is returned for convinience.
 func (results *Set) Add(res Result) Set {
-	results.results = append(results.results, res)
+	results.entries = append(results.entries, setEntry{res, nil})
+	return *results
+}
+
+// AddSet adds a child set to the result set.
+// The result set is returned for convinience.
+func (results *Set) AddSet(child Set) Set {
+	results.entries = append(results.entries, setEntry{Result{}, &child})
 	return *results
 }
 
@@ -78,7 +215,21 @@ func (results *Set) SetMessage(message string) {
 }
 
 // DefaultMessage computes a default message based on the object state.
+// Skipped and Warning results are counted as success.
+// The number of results for each status is detailed.
 func (results Set) DefaultMessage() string {
+	msg := results.successMessage()
+
+	stats := results.Statistics()
+	delete(stats, Error)
+	if details := stats.String(); details != "" {
+		msg += " (" + details + ")"
+	}
+
+	return msg
+}
+
+func (results Set) successMessage() string {
 	tot, success, failures := results.statistics()
 
 	if tot == 0 {
@@ -102,12 +253,81 @@ func (results Set) DefaultMessage() string {
 func (results Set) statistics() (int, int, int) {
 	success := 0
 	failures := 0
-	for _, element := range results.results {
+	all := results.Results()
+	for _, element := range all {
 		if element.IsSuccess() {
 			success++
 		} else {
 			failures++
 		}
 	}
-	return len(results.results), success, failures
+	return len(all), success, failures
+}
+
+// =============================================
+
+// Total gets the number of results.
+func (stats Statistics) Total() int {
+	total := 0
+	for _, count := range stats {
+		total += count
+	}
+	return total
+}
+
+// String gets a summary like "2 created, 1 updated, 3 unchanged".
+// Statuses without results are omitted.
+func (stats Statistics) String() string {
+	var details []string
+	for status := Created; status <= Warning; status++ {
+		count := stats[status]
+		if count == 0 {
+			continue
+		}
+		name := strings.ToLower(status.String())
+		if count > 1 && (status == Warning || status == Error) {
+			name += "s"
+		}
+		details = append(details, strconv.Itoa(count)+" "+name)
+	}
+	return strings.Join(details, ", ")
+}
+
+// =============================================
+
+// multiError joins several errors, one per line.
+// errors.Is and errors.As check each of them, using the Is and As methods
+// (Unwrap() []error is only used by errors.Is and errors.As from Go 1.20).
+type multiError []error
+
+func (errs multiError) Error() string {
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (errs multiError) Unwrap() []error {
+	return errs
+}
+
+// Is is used by errors.Is.
+func (errs multiError) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As is used by errors.As.
+func (errs multiError) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,189 @@
+package zipfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"strings"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// Diff compares two zip files.
+// See ZipArchive.Diff.
+func Diff(oldPath, newPath string, byContent bool) result.Set {
+	message := "Differences between " + oldPath + " and " + newPath
+
+	oldArchive, err := OpenArchive(strings.Replace(oldPath, "~", filesystem.HomeDir(), 1))
+	if err != nil {
+		return result.NewSet([]result.Result{result.FromError(err, "")}, message)
+	}
+	defer oldArchive.Close()
+
+	newArchive, err := OpenArchive(strings.Replace(newPath, "~", filesystem.HomeDir(), 1))
+	if err != nil {
+		return result.NewSet([]result.Result{result.FromError(err, "")}, message)
+	}
+	defer newArchive.Close()
+
+	return oldArchive.Diff(newArchive, byContent)
+}
+
+// Diff compares the entries of the archive (the old one) with the ones of another archive (the new one).
+// Added entries are Created, missing ones are Removed,
+// entries with another content or another mode are Updated, the other ones are Unchanged.
+// The content is compared using the CRC and the size of the entries,
+// or using a SHA-256 hash of the content if byContent is true (the entries are read).
+func (archive *ZipArchive) Diff(other *ZipArchive, byContent bool) result.Set {
+	results := result.NewSet(nil, "Differences between "+archive.path+" and "+other.path)
+
+	for _, entry := range archive.uniqueEntries() {
+		newEntry := other.GetFile(entry.Name())
+		if newEntry == nil {
+			results.Add(result.NewRemoved(entry.Name() + " removed"))
+			continue
+		}
+		results.Add(diffEntry(entry, *newEntry, byContent))
+	}
+
+	for _, entry := range other.uniqueEntries() {
+		if !archive.HasFile(entry.Name()) {
+			results.Add(result.NewCreated(entry.Name() + " added"))
+		}
+	}
+
+	return results
+}
+
+// Verify checks the CRC of all the entries of a zip file.
+// See ZipArchive.Verify.
+func Verify(filePath string) result.Set {
+	archive, err := OpenArchive(strings.Replace(filePath, "~", filesystem.HomeDir(), 1))
+	if err != nil {
+		return result.NewSet([]result.Result{result.FromError(err, "")}, "Verification of "+filePath)
+	}
+	defer archive.Close()
+
+	return archive.Verify()
+}
+
+// Verify reads all the entries, checking their CRC.
+// Corrupted entries are Errors, intact ones are Info.
+// The content is NOT kept into memory.
+func (archive *ZipArchive) Verify() result.Set {
+	results := result.NewSet(nil, "Verification of "+archive.path)
+
+	for _, entry := range archive.entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		rc, err := entry.file.Open()
+		if err == nil {
+			_, err = io.Copy(io.Discard, rc)
+			rc.Close()
+		}
+
+		if err != nil {
+			results.Add(result.FromError(err, entry.Name()+" is corrupted"))
+		} else {
+			results.Add(result.NewInfo(entry.Name() + " is intact"))
+		}
+	}
+
+	return results
+}
+
+// Verify reports the elements which could NOT be loaded into memory (see IsValid), as Errors.
+// Since their CRC is checked while loading, the other ones are intact.
+func (zip ZipFile) Verify() result.Set {
+	results := result.NewSet(nil, "Verification of the zip file")
+	if zip.Files == nil && zip.Err != nil {
+		results.Add(result.FromError(zip.Err, ""))
+		return results
+	}
+
+	for _, file := range zip.Files {
+		if file.IsValid() {
+			results.Add(result.NewInfo(file.name + " is intact"))
+		} else {
+			results.Add(result.NewError(file.name + " is corrupted"))
+		}
+	}
+	return results
+}
+
+// =============================================
+
+// uniqueEntries gets the entries, without the duplicated names (only the first one is kept, like GetFile).
+func (archive *ZipArchive) uniqueEntries() []ZipEntry {
+	var list []ZipEntry
+	for idx, entry := range archive.entries {
+		if archive.index[entry.Name()] == idx {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func diffEntry(oldEntry, newEntry ZipEntry, byContent bool) result.Result {
+	var changes []string
+
+	if !oldEntry.IsDir() {
+		same, err := sameEntryContent(oldEntry, newEntry, byContent)
+		if err != nil {
+			return result.FromError(err, "Can't compare "+oldEntry.Name())
+		}
+		if !same {
+			changes = append(changes, "content modified")
+		}
+	}
+
+	if oldMode, newMode := extractMode(oldEntry), extractMode(newEntry); oldMode != newMode {
+		changes = append(changes, "mode changed from "+oldMode.String()+" to "+newMode.String())
+	}
+
+	if len(changes) == 0 {
+		return result.NewUnchanged(oldEntry.Name() + " unchanged")
+	}
+	return result.NewUpdated(oldEntry.Name() + " " + strings.Join(changes, ", "))
+}
+
+func sameEntryContent(oldEntry, newEntry ZipEntry, byContent bool) (bool, error) {
+	if oldEntry.Size() != newEntry.Size() {
+		return false, nil
+	}
+	if !byContent {
+		return oldEntry.CRC32() == newEntry.CRC32(), nil
+	}
+
+	oldHash, err := entryHash(oldEntry)
+	if err != nil {
+		return false, err
+	}
+	newHash, err := entryHash(newEntry)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(oldHash, newHash), nil
+}
+
+func entryHash(entry ZipEntry) ([]byte, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rc); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,88 @@
+package zipfile
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/internal/extract"
+	"github.com/gandrille/go-commons/result"
+)
+
+// IMPORTANT! READ ME FIRST!
+// All the functions in this file are designed for providing nice status messages,
+// NOT for efficiency optimization.
+// Do NOT use this functions if you need performance.
+
+// ExtractOptions configures the extraction of an archive.
+type ExtractOptions = extract.Options
+
+// ExtractAll extracts all the entries of a zip file into a destination folder.
+// See ZipArchive.ExtractAll.
+func ExtractAll(filePath, dest string, options ExtractOptions) result.Set {
+	return ExtractPrefix(filePath, "", dest, options)
+}
+
+// ExtractPrefix extracts the entries of a zip file starting with a prefix into a destination folder.
+// See ZipArchive.ExtractPrefix.
+func ExtractPrefix(filePath, prefix, dest string, options ExtractOptions) result.Set {
+	filePath = strings.Replace(filePath, "~", filesystem.HomeDir(), 1)
+
+	archive, err := OpenArchive(filePath)
+	if err != nil {
+		return result.NewSet([]result.Result{result.FromError(err, "")}, "Extraction of "+filePath+" into "+dest)
+	}
+	defer archive.Close()
+
+	return archive.ExtractPrefix(prefix, dest, options)
+}
+
+// ExtractAll extracts all the entries into a destination folder.
+// Entries which would be written outside of the destination folder
+// (absolute names, "../" elements, symlinks pointing outside) are rejected with an Error.
+// Permissions, modification times and symlinks are restored.
+// Files which already have the expected content are Unchanged.
+func (archive *ZipArchive) ExtractAll(dest string, options ExtractOptions) result.Set {
+	return archive.ExtractPrefix("", dest, options)
+}
+
+// ExtractPrefix extracts the entries starting with a prefix (ie "doc/") into a destination folder.
+// The prefix is removed from the names before applying options.StripComponents.
+// See ExtractAll for the safety checks.
+func (archive *ZipArchive) ExtractPrefix(prefix, dest string, options ExtractOptions) result.Set {
+	results := result.NewSet(nil, "Extraction of "+archive.path+" into "+dest)
+
+	x, err := extract.New(dest, options)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't extract "+archive.path))
+		return results
+	}
+
+	for _, entry := range archive.FilesStartingWith(prefix) {
+		name := strings.TrimPrefix(entry.Name(), prefix)
+		if name == "" {
+			continue
+		}
+		if res, extracted := x.Extract(name, extractMode(entry), entry.ModTime(), entry.Open); extracted {
+			results.Add(res)
+		}
+	}
+
+	for _, res := range x.Finish() {
+		results.Add(res)
+	}
+	return results
+}
+
+// extractMode gets the mode of an entry.
+// Zip files created on other systems than Unix do NOT have meaningful permissions.
+func extractMode(entry ZipEntry) os.FileMode {
+	mode := entry.Mode()
+	if creator := entry.file.CreatorVersion >> 8; creator == 3 || creator == 19 {
+		return mode
+	}
+	if mode.IsDir() {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
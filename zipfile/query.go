@@ -0,0 +1,154 @@
+package zipfile
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/internal/extract"
+	"github.com/gandrille/go-commons/result"
+)
+
+// FilesMatchingGlob returns the zip elements with their names matching a glob pattern,
+// supporting "**" (see filesystem.MatchGlob).
+func (zip ZipFile) FilesMatchingGlob(pattern string) ([]ZipElement, error) {
+	if _, err := filesystem.MatchGlob(pattern, ""); err != nil {
+		return nil, err
+	}
+	return zip.FilesMatching(func(file ZipElement) bool {
+		matched, _ := filesystem.MatchGlob(pattern, file.name)
+		return matched
+	}), nil
+}
+
+// FilesMatchingRegexp returns the zip elements with their names matching a regular expression.
+func (zip ZipFile) FilesMatchingRegexp(re *regexp.Regexp) []ZipElement {
+	return zip.FilesMatching(func(file ZipElement) bool {
+		return re.MatchString(file.name)
+	})
+}
+
+// FilesMatching returns the zip elements for which the predicate is true.
+func (zip ZipFile) FilesMatching(predicate func(ZipElement) bool) []ZipElement {
+	var list []ZipElement
+	for _, file := range zip.Files {
+		if predicate(file) {
+			list = append(list, file)
+		}
+	}
+	return list
+}
+
+// Children gets the sorted names of the files and folders directly inside a folder ("" for the root).
+// Folder names end with "/", including the ones which are NOT stored in the zip file.
+func (zip ZipFile) Children(dir string) []string {
+	var names []string
+	for _, file := range zip.Files {
+		names = append(names, file.name)
+	}
+	return children(names, dir)
+}
+
+// =============================================
+
+// FilesMatchingGlob returns the entries with their names matching a glob pattern,
+// supporting "**" (see filesystem.MatchGlob).
+func (archive *ZipArchive) FilesMatchingGlob(pattern string) ([]ZipEntry, error) {
+	if _, err := filesystem.MatchGlob(pattern, ""); err != nil {
+		return nil, err
+	}
+	return archive.FilesMatching(func(entry ZipEntry) bool {
+		matched, _ := filesystem.MatchGlob(pattern, entry.Name())
+		return matched
+	}), nil
+}
+
+// FilesMatchingRegexp returns the entries with their names matching a regular expression.
+func (archive *ZipArchive) FilesMatchingRegexp(re *regexp.Regexp) []ZipEntry {
+	return archive.FilesMatching(func(entry ZipEntry) bool {
+		return re.MatchString(entry.Name())
+	})
+}
+
+// FilesMatching returns the entries for which the predicate is true.
+func (archive *ZipArchive) FilesMatching(predicate func(ZipEntry) bool) []ZipEntry {
+	var list []ZipEntry
+	for _, entry := range archive.entries {
+		if predicate(entry) {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// Children gets the sorted names of the files and folders directly inside a folder ("" for the root).
+// Folder names end with "/", including the ones which are NOT stored in the zip file.
+func (archive *ZipArchive) Children(dir string) []string {
+	var names []string
+	for _, entry := range archive.entries {
+		names = append(names, entry.Name())
+	}
+	return children(names, dir)
+}
+
+// ExtractGlob extracts the entries matching a glob pattern (ie "META-INF/**/*.xml") into a destination folder.
+// The names are kept, use options.StripComponents to remove leading folders.
+// See ExtractAll for the safety checks.
+func (archive *ZipArchive) ExtractGlob(pattern, dest string, options ExtractOptions) result.Set {
+	results := result.NewSet(nil, "Extraction of "+pattern+" from "+archive.path+" into "+dest)
+
+	entries, err := archive.FilesMatchingGlob(pattern)
+	if err != nil {
+		results.Add(result.FromError(err, "Invalid pattern "+pattern))
+		return results
+	}
+
+	x, err := extract.New(dest, options)
+	if err != nil {
+		results.Add(result.FromError(err, "Can't extract "+archive.path))
+		return results
+	}
+
+	for _, entry := range entries {
+		if res, extracted := x.Extract(entry.Name(), extractMode(entry), entry.ModTime(), entry.Open); extracted {
+			results.Add(res)
+		}
+	}
+	for _, res := range x.Finish() {
+		results.Add(res)
+	}
+	return results
+}
+
+// =============================================
+
+// children gets the direct children of a folder, from a list of names.
+func children(names []string, dir string) []string {
+	prefix := strings.Trim(dir, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	found := map[string]bool{}
+	var list []string
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+
+		child := strings.TrimPrefix(name, prefix)
+		if idx := strings.Index(child, "/"); idx != -1 {
+			child = child[:idx+1]
+		}
+		if child == "/" || path.Clean(child) == ".." || found[child] {
+			continue
+		}
+		found[child] = true
+		list = append(list, child)
+	}
+
+	sort.Strings(list)
+	return list
+}
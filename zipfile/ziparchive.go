@@ -0,0 +1,203 @@
+package zipfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/internal/archivefs"
+)
+
+// ====================================
+// Streaming access to zip files,
+// whatever their size.
+// The content of an entry is only
+// read when it is opened.
+// ====================================
+
+// ZipArchive is an open zip file.
+// It MUST be closed after usage.
+// It implements fs.FS, fs.ReadDirFS, fs.GlobFS and fs.StatFS,
+// with the folders which are NOT stored in the zip file synthesized from the names of the files.
+type ZipArchive struct {
+	path    string
+	reader  *zip.ReadCloser
+	entries []ZipEntry
+	index   map[string]int
+	fsys    *archivefs.FS
+}
+
+// ZipEntry is a file (or a folder) inside a zip archive.
+// Its content is NOT loaded into memory.
+type ZipEntry struct {
+	file *zip.File
+}
+
+// OpenArchive opens a zip file for reading.
+// Only the central directory is read.
+func OpenArchive(filePath string) (*ZipArchive, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, errors.New(filePath + " can't open file: " + err.Error())
+	}
+
+	archive := ZipArchive{filePath, reader, nil, map[string]int{}, nil}
+	var fsEntries []archivefs.Entry
+	for idx, f := range reader.File {
+		archive.entries = append(archive.entries, ZipEntry{f})
+		fsEntries = append(fsEntries, ZipEntry{f})
+		if _, found := archive.index[f.Name]; !found {
+			archive.index[f.Name] = idx
+		}
+	}
+	archive.fsys = archivefs.New(fsEntries)
+	return &archive, nil
+}
+
+// Path gets the path of the zip file.
+func (archive *ZipArchive) Path() string {
+	return archive.path
+}
+
+// Close closes the zip file.
+// The entries can't be opened anymore.
+func (archive *ZipArchive) Close() error {
+	return archive.reader.Close()
+}
+
+// Entries gets all the entries, in the zip file order.
+func (archive *ZipArchive) Entries() []ZipEntry {
+	return archive.entries
+}
+
+// HasFile checks if an entry is part of the archive.
+func (archive *ZipArchive) HasFile(name string) bool {
+	return archive.GetFile(name) != nil
+}
+
+// GetFile returns the entry matching a given name, or nil if NOT found.
+func (archive *ZipArchive) GetFile(name string) *ZipEntry {
+	if idx, found := archive.index[name]; found {
+		entry := archive.entries[idx]
+		return &entry
+	}
+	return nil
+}
+
+// FilesStartingWith returns the entries with their names starting with a given prefix.
+func (archive *ZipArchive) FilesStartingWith(prefix string) []ZipEntry {
+	var list []ZipEntry
+	for _, entry := range archive.entries {
+		if strings.HasPrefix(entry.Name(), prefix) {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// Open opens a file or a folder, for fs.FS.
+// name is a slash separated path, without any trailing slash (ie "doc/index.html").
+// Use GetFile to get an entry by its raw name.
+func (archive *ZipArchive) Open(name string) (fs.File, error) {
+	return archive.fsys.Open(name)
+}
+
+// ReadDir reads a folder, for fs.ReadDirFS.
+func (archive *ZipArchive) ReadDir(name string) ([]fs.DirEntry, error) {
+	return archive.fsys.ReadDir(name)
+}
+
+// Glob gets the names of the files and folders matching a pattern, for fs.GlobFS.
+func (archive *ZipArchive) Glob(pattern string) ([]string, error) {
+	return archive.fsys.Glob(pattern)
+}
+
+// Stat gets the information about a file or a folder, for fs.StatFS.
+func (archive *ZipArchive) Stat(name string) (fs.FileInfo, error) {
+	return archive.fsys.Stat(name)
+}
+
+// Load reads the content of all the entries into memory.
+func (archive *ZipArchive) Load() ZipFile {
+	var files []ZipElement
+	var invalid []string
+	for _, entry := range archive.entries {
+		element := entry.Load()
+		if !element.IsValid() {
+			invalid = append(invalid, element.name)
+		}
+		files = append(files, element)
+	}
+
+	if len(invalid) != 0 {
+		return NewZipFile(files, errors.New(archive.path+" can't read embedded files: "+strings.Join(invalid, ",")))
+	}
+	return NewZipFile(files, nil)
+}
+
+// =============================================
+
+// Name gets the name (zip relative path) of this entry.
+func (entry ZipEntry) Name() string {
+	return entry.file.Name
+}
+
+// Size gets the uncompressed size, in bytes.
+func (entry ZipEntry) Size() uint64 {
+	return entry.file.UncompressedSize64
+}
+
+// CompressedSize gets the compressed size, in bytes.
+func (entry ZipEntry) CompressedSize() uint64 {
+	return entry.file.CompressedSize64
+}
+
+// CRC32 gets the checksum of the uncompressed content.
+func (entry ZipEntry) CRC32() uint32 {
+	return entry.file.CRC32
+}
+
+// ModTime gets the modification time.
+func (entry ZipEntry) ModTime() time.Time {
+	return entry.file.Modified
+}
+
+// Mode gets the permission and mode bits.
+func (entry ZipEntry) Mode() os.FileMode {
+	return entry.file.Mode()
+}
+
+// IsDir checks if the entry is a folder.
+func (entry ZipEntry) IsDir() bool {
+	return entry.file.Mode().IsDir()
+}
+
+// Open opens the content of the entry for reading.
+// The checksum is verified when the end of the content is reached.
+func (entry ZipEntry) Open() (io.ReadCloser, error) {
+	rc, err := entry.file.Open()
+	if err != nil {
+		return nil, errors.New(entry.Name() + " can't be opened: " + err.Error())
+	}
+	return rc, nil
+}
+
+// Load reads the content of the entry into memory.
+func (entry ZipEntry) Load() ZipElement {
+	rc, err := entry.file.Open()
+	if err != nil {
+		return ZipElement{entry.Name(), []byte{}, false}
+	}
+	defer rc.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(rc); err != nil {
+		return ZipElement{entry.Name(), []byte{}, false}
+	}
+	return ZipElement{entry.Name(), buf.Bytes(), true}
+}
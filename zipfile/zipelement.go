@@ -19,6 +19,15 @@ type ZipElement struct {
 	isValid bool
 }
 
+// NewZipElement constructs a ZipElement object.
+// The element is invalid if err is NOT nil (ie the content can't be read).
+func NewZipElement(name string, content []byte, err error) ZipElement {
+	if err != nil {
+		return ZipElement{name, []byte{}, false}
+	}
+	return ZipElement{name, content, true}
+}
+
 // Name gets the name (zip relative path) of this element.
 func (element ZipElement) Name() string {
 	return element.name
@@ -1,8 +1,6 @@
 package zipfile
 
 import (
-	"archive/zip"
-	"bytes"
 	"errors"
 	"strings"
 )
@@ -12,6 +10,7 @@ import (
 // Why only SMALL ones?
 // Because the full content of the zip
 // file is loaded into memory...
+// See ZipArchive for big ones.
 // ====================================
 
 // ZipFile is the in memory representation of an existing zip file.
@@ -20,50 +19,29 @@ type ZipFile struct {
 	Err   error
 }
 
+// ZipIndex is a snapshot of the elements of a ZipFile, indexed by name for O(1) lookups.
+// It is NOT updated when the Files of the ZipFile are modified: build a new one.
+type ZipIndex struct {
+	files []ZipElement
+	index map[string]int // position of the first element of each name
+}
+
+// NewZipFile constructs a ZipFile object.
+func NewZipFile(files []ZipElement, err error) ZipFile {
+	return ZipFile{files, err}
+}
+
 // Open loads a zip file into memory for easy usage.
 // It does NOT requires closing it.
+// Use OpenArchive for big zip files.
 func Open(filePath string) ZipFile {
-	var files []ZipElement
-
-	// Open zip file
-	r, err := zip.OpenReader(filePath)
+	archive, err := OpenArchive(filePath)
 	if err != nil {
-		return ZipFile{files, errors.New(filePath + " can't open file")}
-	}
-	defer r.Close()
-
-	// Read files embedded into the zip file
-	for _, f := range r.File {
-		name := f.Name
-		rc, err := f.Open()
-		if err != nil {
-			files = append(files, ZipElement{name, []byte{}, false})
-		} else {
-			buf := new(bytes.Buffer)
-			_, err = buf.ReadFrom(rc)
-			if err != nil {
-				files = append(files, ZipElement{name, []byte{}, false})
-			} else {
-				files = append(files, ZipElement{name, buf.Bytes(), true})
-			}
-			rc.Close()
-		}
-	}
-
-	// Build invalid files list
-	var invalid []string
-	for _, file := range files {
-		if !file.IsValid() {
-			invalid = append(invalid, file.name)
-		}
+		return NewZipFile(nil, errors.New(filePath+" can't open file"))
 	}
+	defer archive.Close()
 
-	// Return
-	if len(invalid) == 0 {
-		return ZipFile{files, nil}
-	} else {
-		return ZipFile{files, errors.New(filePath + " can't read embedded files: " + strings.Join(invalid, ","))}
-	}
+	return archive.Load()
 }
 
 // IsValid checks if a zip file has been loaded into memory.
@@ -78,6 +56,7 @@ func (zip ZipFile) HasFile(name string) bool {
 }
 
 // GetFile returns a zip element matching a given name, or nil if NOT found.
+// Use Index for many lookups.
 func (zip ZipFile) GetFile(name string) *ZipElement {
 	for _, file := range zip.Files {
 		if file.name == name {
@@ -87,6 +66,33 @@ func (zip ZipFile) GetFile(name string) *ZipElement {
 	return nil
 }
 
+// Index builds a snapshot of the elements, indexed by name.
+func (zip ZipFile) Index() ZipIndex {
+	files := append([]ZipElement(nil), zip.Files...)
+	index := map[string]int{}
+	for idx, file := range files {
+		if _, found := index[file.name]; !found {
+			index[file.name] = idx
+		}
+	}
+	return ZipIndex{files, index}
+}
+
+// HasFile checks if a zip element is part of the snapshot.
+func (index ZipIndex) HasFile(name string) bool {
+	_, found := index.index[name]
+	return found
+}
+
+// GetFile returns the first zip element of the snapshot matching a given name, or nil if NOT found.
+func (index ZipIndex) GetFile(name string) *ZipElement {
+	if idx, found := index.index[name]; found {
+		file := index.files[idx]
+		return &file
+	}
+	return nil
+}
+
 // FilesStartingWith returns a slice of zip element with their names starting with a given prefix.
 func (zip ZipFile) FilesStartingWith(prefix string) []ZipElement {
 	var list []ZipElement
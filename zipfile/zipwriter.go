@@ -0,0 +1,379 @@
+package zipfile
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gandrille/go-commons/filesystem"
+	"github.com/gandrille/go-commons/internal/extract"
+	"github.com/gandrille/go-commons/internal/pathutil"
+	"github.com/gandrille/go-commons/result"
+)
+
+// Compression method of a zip entry.
+type Compression int
+
+const (
+	DefaultCompression Compression = iota // the compression of the builder, or Deflate
+	Deflate
+	Store // no compression, for already compressed content
+)
+
+// ZipOptions configures a ZipBuilder.
+type ZipOptions struct {
+	Compression  Compression // compression of the entries using DefaultCompression
+	Reproducible bool        // entries are sorted by name and all have the same modification time
+	ModTime      time.Time   // modification time of the entries of reproducible archives, 1980-01-01 if zero
+}
+
+// ZipSource is an entry to be written in a zip file.
+// The content is Content, or the content of File if set.
+type ZipSource struct {
+	Name        string      // zip relative path, using "/", ending with "/" for folders
+	Content     []byte      // content of the entry (the target for symlinks)
+	File        string      // file to copy the content from, read when the zip file is written
+	Mode        os.FileMode // 0644 for files and 0755 for folders if zero
+	ModTime     time.Time   // current time if zero
+	Compression Compression
+
+	raw *zip.File // entry of an existing zip file, copied without decompression
+}
+
+// ZipBuilder collects entries, then writes them as a zip file.
+type ZipBuilder struct {
+	options ZipOptions
+	sources []ZipSource
+	index   map[string]int
+}
+
+// NewZipBuilder constructs an empty ZipBuilder.
+func NewZipBuilder(options ZipOptions) *ZipBuilder {
+	return &ZipBuilder{options, nil, map[string]int{}}
+}
+
+// Names gets the names of the entries, in the order they have been added.
+func (builder *ZipBuilder) Names() []string {
+	var names []string
+	for _, source := range builder.sources {
+		names = append(names, source.Name)
+	}
+	return names
+}
+
+// Add adds an entry.
+// An entry with the same name is replaced.
+func (builder *ZipBuilder) Add(source ZipSource) {
+	if idx, found := builder.index[source.Name]; found {
+		builder.sources[idx] = source
+		return
+	}
+	builder.index[source.Name] = len(builder.sources)
+	builder.sources = append(builder.sources, source)
+}
+
+// AddContent adds a file with an in memory content.
+func (builder *ZipBuilder) AddContent(name string, content []byte) {
+	builder.Add(ZipSource{Name: name, Content: content})
+}
+
+// AddFile adds a file, a folder or a symlink from the filesystem, with its mode and its modification time.
+// The content of regular files is only read when the zip file is written.
+func (builder *ZipBuilder) AddFile(name, filePath string) error {
+	filePath = strings.Replace(filePath, "~", filesystem.HomeDir(), 1)
+
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		return errors.New("Can't add " + filePath + " to zip file: " + err.Error())
+	}
+
+	source := ZipSource{Name: name, Mode: info.Mode(), ModTime: info.ModTime()}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return errors.New("Can't add " + filePath + " to zip file: " + err.Error())
+		}
+		source.Content = []byte(target)
+	case info.IsDir():
+		if !strings.HasSuffix(source.Name, "/") {
+			source.Name += "/"
+		}
+	case info.Mode().IsRegular():
+		source.File = filePath
+	default:
+		return errors.New("Can't add " + filePath + " to zip file: unsupported file type")
+	}
+
+	builder.Add(source)
+	return nil
+}
+
+// AddTree adds the content of a folder, recursively, under a prefix (ie "doc/", or "" for the root).
+// include and exclude are glob patterns (see filesystem.MatchGlob) matching the slash separated relative paths,
+// or only the base names for patterns without "/".
+// If include is empty, all files are included. Excluded folders are NOT visited.
+func (builder *ZipBuilder) AddTree(prefix, folderPath string, include, exclude []string) error {
+	folderPath = strings.Replace(folderPath, "~", filesystem.HomeDir(), 1)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return filepath.Walk(folderPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.New("Can't add " + filePath + " to zip file: " + err.Error())
+		}
+		if filePath == folderPath {
+			return nil
+		}
+
+		rel, err := filepath.Rel(folderPath, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if pathutil.MatchPatterns(exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			if len(include) == 0 {
+				return builder.AddFile(prefix+rel+"/", filePath)
+			}
+			return nil
+		}
+		if len(include) != 0 && !pathutil.MatchPatterns(include, rel) {
+			return nil
+		}
+
+		// Folders are added on demand when only some files are included
+		if len(include) != 0 {
+			for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+				if err := builder.AddFile(prefix+dir+"/", filepath.Join(folderPath, dir)); err != nil {
+					return err
+				}
+			}
+		}
+		return builder.AddFile(prefix+rel, filePath)
+	})
+}
+
+// Write writes the zip file content.
+func (builder *ZipBuilder) Write(w io.Writer) error {
+	sources := append([]ZipSource{}, builder.sources...)
+	if builder.options.Reproducible {
+		sort.SliceStable(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	}
+
+	zw := zip.NewWriter(w)
+	for _, source := range sources {
+		if err := builder.writeEntry(zw, source); err != nil {
+			return errors.New("Can't write " + source.Name + " in zip file: " + err.Error())
+		}
+	}
+	return zw.Close()
+}
+
+// WriteFile writes the zip file to the filesystem.
+// Since the modification times are stored, only reproducible archives are expected to be Unchanged.
+func (builder *ZipBuilder) WriteFile(filePath string, writeIfFileExists bool) result.Result {
+	fileName := strings.Replace(filePath, filesystem.HomeDir(), "~", 1)
+	filePath = strings.Replace(filePath, "~", filesystem.HomeDir(), 1)
+
+	exists, err := filesystem.RegularFileExists(filePath)
+	if err != nil {
+		return result.NewError(fileName + " exists but is not a regular file")
+	}
+	if exists && !writeIfFileExists {
+		return result.NewUnchanged(fileName + " already exists")
+	}
+
+	// The zip file is written next to the target, then moved
+	tmp, err := filesystem.CreateAtomicFile(filePath)
+	if err != nil {
+		return result.FromError(err, fileName+" writing error")
+	}
+	defer tmp.Abort()
+
+	if err := builder.Write(tmp); err != nil {
+		return result.FromError(err, fileName+" writing error")
+	}
+
+	if exists {
+		same, err := filesystem.SameContent(filePath, tmp.Name())
+		if err != nil {
+			return result.FromError(err, fileName+" already exists but we can't read its content")
+		}
+		if same {
+			return result.NewUnchanged(fileName + " already has expected content")
+		}
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := tmp.Commit(mode, time.Time{}); err != nil {
+		return result.FromError(err, fileName+" writing error")
+	}
+
+	if exists {
+		return result.NewUpdated(fileName + " updated")
+	}
+	return result.NewCreated(fileName + " created")
+}
+
+// UpdateZipFile rewrites an existing zip file.
+// The entries of the builder are added, or replace the existing ones with the same name,
+// and the entries listed in remove are removed (with their content for folders, ie "doc/").
+// The other entries are copied without being decompressed.
+func UpdateZipFile(filePath string, builder *ZipBuilder, remove []string) result.Result {
+	filePath = strings.Replace(filePath, "~", filesystem.HomeDir(), 1)
+
+	archive, err := OpenArchive(filePath)
+	if err != nil {
+		return result.FromError(err, "")
+	}
+	defer archive.Close()
+
+	replaced := map[string]bool{}
+	for _, name := range builder.Names() {
+		replaced[name] = true
+	}
+
+	merged := NewZipBuilder(builder.options)
+	for _, entry := range archive.Entries() {
+		if !replaced[entry.Name()] && !isRemoved(entry.Name(), remove) {
+			merged.Add(ZipSource{Name: entry.Name(), raw: entry.file})
+		}
+	}
+	for _, source := range builder.sources {
+		merged.Add(source)
+	}
+
+	return merged.WriteFile(filePath, true)
+}
+
+// =============================================
+
+func (builder *ZipBuilder) writeEntry(zw *zip.Writer, source ZipSource) error {
+	if err := extract.CheckName(source.Name); err != nil {
+		return err
+	}
+
+	modTime := source.ModTime
+	if builder.options.Reproducible {
+		modTime = builder.options.ModTime
+		if modTime.IsZero() {
+			modTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+	}
+
+	// Entry copied from another zip file
+	if source.raw != nil {
+		header := source.raw.FileHeader
+		header.Name = source.Name
+		if builder.options.Reproducible {
+			setRawModTime(&header, modTime)
+		}
+		w, err := zw.CreateRaw(&header)
+		if err != nil {
+			return err
+		}
+		r, err := source.raw.OpenRaw()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		return err
+	}
+
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	header := zip.FileHeader{Name: source.Name, Modified: modTime, Method: builder.method(source)}
+	header.SetMode(entryMode(source))
+	if strings.HasSuffix(source.Name, "/") {
+		header.Method = zip.Store
+	}
+
+	w, err := zw.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	if source.File == "" {
+		_, err = w.Write(source.Content)
+		return err
+	}
+
+	file, err := os.Open(source.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (builder *ZipBuilder) method(source ZipSource) uint16 {
+	compression := source.Compression
+	if compression == DefaultCompression {
+		compression = builder.options.Compression
+	}
+	if compression == Store {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+func entryMode(source ZipSource) os.FileMode {
+	if source.Mode != 0 {
+		return source.Mode
+	}
+	if strings.HasSuffix(source.Name, "/") {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func isRemoved(name string, remove []string) bool {
+	for _, cur := range remove {
+		if name == cur || (strings.HasSuffix(cur, "/") && strings.HasPrefix(name, cur)) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRawModTime sets the modification time of a header written with CreateRaw,
+// which does NOT compute the MS-DOS and the extended timestamp fields.
+func setRawModTime(header *zip.FileHeader, t time.Time) {
+	t = t.UTC()
+	header.Modified = t
+	header.ModifiedDate = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	header.ModifiedTime = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+
+	// Extended timestamp extra field (0x5455): flags, then modification time
+	var extra []byte
+	for data := header.Extra; len(data) >= 4; {
+		size := 4 + int(data[2]) + int(data[3])<<8
+		if size > len(data) {
+			break
+		}
+		if data[0] != 0x55 || data[1] != 0x54 {
+			extra = append(extra, data[:size]...)
+		}
+		data = data[size:]
+	}
+	mt := uint32(t.Unix())
+	header.Extra = append(extra, 0x55, 0x54, 5, 0, 1, byte(mt), byte(mt>>8), byte(mt>>16), byte(mt>>24))
+}